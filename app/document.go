@@ -30,8 +30,8 @@ import (
 )
 
 var LanguageCmdPairs = []resolver.ResolveOption{
-	{Lang: []string{"sh", "shell"}, Command: "sh", CmdOpt: "-c"},
-	{Lang: []string{"bash"}, Command: "bash", CmdOpt: "-c"},
+	{Lang: []string{"sh", "shell"}, Command: "sh", CmdOpt: "-c", Arg0: true},
+	{Lang: []string{"bash"}, Command: "bash", CmdOpt: "-c", Arg0: true},
 	{Lang: []string{"powershell", "posh"}, Command: "powershell", CmdOpt: "-Command"},
 	{Lang: []string{"py", "python"}, Command: "python", CmdOpt: "-c"},
 	{Lang: []string{"js", "javascript"}, Command: "node", CmdOpt: "-e"},
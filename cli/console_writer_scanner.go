@@ -52,6 +52,7 @@ type ConsoleWriterScaner struct {
 	labelDecoration Decoration
 	kind            string
 	label           string
+	capture         io.Writer
 }
 
 var adjustLabelPrefix = regexp.MustCompile(`^[^a-zA-Z0-9]*[a-zA-Z0-9]+[^a-zA-Z0-9]?`)
@@ -101,6 +102,12 @@ func (cw *ConsoleWriter) NewScanner(labelDecoration Decoration, kind string, lab
 	return scanner
 }
 
+// Writes the scanned lines also to w without the label, in the same lines as the console.
+func (cws *ConsoleWriterScaner) Capture(w io.Writer) *ConsoleWriterScaner {
+	cws.capture = w
+	return cws
+}
+
 func (cws *ConsoleWriterScaner) Scan(reader io.Reader) {
 
 	ch := cws.dest.chRecord
@@ -148,6 +155,10 @@ func (cws *ConsoleWriterScaner) Scan(reader io.Reader) {
 			panic("invalid split")
 		}
 
+		if cws.capture != nil {
+			cws.capture.Write(append(line[:len(line):len(line)], '\n'))
+		}
+
 		ch <- ConsoleRecord{
 			sender:          cws,
 			RecordMode:      mode,
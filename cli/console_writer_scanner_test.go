@@ -41,6 +41,28 @@ func TestConsoleWriterScanner(t *testing.T) {
 		Scan(bytes.NewBufferString(strings.Repeat("012345678901234567890123456789\n", 1000)))
 }
 
+func TestConsoleWriterScannerCapture(t *testing.T) {
+	console := bytes.Buffer{}
+	cw, _ := NewConsoleWriter(&console)
+	cwWaiter := sync.WaitGroup{}
+	cwWaiter.Add(1)
+	go func() {
+		defer cwWaiter.Done()
+		cw.Route()
+	}()
+
+	captured := bytes.Buffer{}
+	cw.NewScanner(Decoration{}, "TEST", "test_str").Capture(&captured).
+		Scan(bytes.NewBufferString("first\r\nsecond\nlast"))
+	cw.Close()
+	cwWaiter.Wait()
+
+	assert.Equal(t, "first\nsecond\nlast\n", captured.String())
+	for _, line := range strings.Split(captured.String(), "\n")[:3] {
+		assert.Contains(t, console.String(), line)
+	}
+}
+
 func TestAdjustLabel(t *testing.T) {
 	assert.Equal(t, "abcdef/...n/opqrstu", adjustLabel("abcdef/ghijklmn/opqrstu"))
 }
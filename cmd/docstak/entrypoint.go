@@ -18,10 +18,12 @@ package main
 
 import (
 	"context"
+	"io"
 	"log/slog"
 	"os"
 	"sync"
 
+	"github.com/kasaikou/markflow/app"
 	"github.com/kasaikou/markflow/cli"
 	"github.com/kasaikou/markflow/docstak"
 )
@@ -41,6 +43,16 @@ func entrypoint(args parseArgResult) int {
 	logger := slog.New(cw.NewLoggerHandler(nil))
 	ctx := docstak.WithLogger(context.Background(), logger)
 
+	if args.SubCommand != "" {
+		args = resolveSubCommand(ctx, args)
+		if params, exist := args.Params[args.SubCommand]; exist && *args.subCommands()[args.SubCommand] {
+			for key, value := range params {
+				logger.Error("parameter must follow the task name", slog.String("sub-command", args.SubCommand), slog.String("parameter", key+"="+value))
+			}
+			return -1
+		}
+	}
+
 	type featureFlag struct {
 		Name   string
 		Enable bool
@@ -53,6 +65,26 @@ func entrypoint(args parseArgResult) int {
 			Enable: *args.DryRun,
 			Fn:     func(ctx context.Context, args parseArgResult) int { return dryrun(ctx, args) },
 		},
+		{
+			Name:   "--list",
+			Enable: *args.List,
+			Fn:     func(ctx context.Context, args parseArgResult) int { return list(ctx, args) },
+		},
+		{
+			Name:   "--graph",
+			Enable: *args.Graph,
+			Fn:     func(ctx context.Context, args parseArgResult) int { return graph(ctx, args) },
+		},
+		{
+			Name:   "--schema",
+			Enable: *args.Schema,
+			Fn:     func(ctx context.Context, args parseArgResult) int { return schema(ctx, args) },
+		},
+		{
+			Name:   "--explain",
+			Enable: *args.Explain,
+			Fn:     func(ctx context.Context, args parseArgResult) int { return explain(ctx, args) },
+		},
 	}
 
 	enabledFeature := []featureFlag{}
@@ -86,3 +118,27 @@ func entrypoint(args parseArgResult) int {
 		return -1
 	}
 }
+
+// Runs the task instead of the sub-command when the document defines the task with the same name.
+func resolveSubCommand(ctx context.Context, args parseArgResult) parseArgResult {
+	logger := docstak.GetLogger(ctx)
+
+	// The errors of the document are reported by the sub-command itself if it needs the document.
+	quiet := docstak.WithLogger(ctx, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	document, success := app.NewLocalDocument(quiet)
+	if !success {
+		return args
+	}
+
+	if _, exist := document.Document.Tasks[args.SubCommand]; !exist {
+		return args
+	}
+
+	logger.Warn("task takes precedence over the sub-command",
+		slog.String("task", args.SubCommand),
+		slog.String("flag", "--"+args.SubCommand),
+	)
+	*args.subCommands()[args.SubCommand] = false
+	args.Cmds = append([]string{args.SubCommand}, args.Cmds...)
+	return args
+}
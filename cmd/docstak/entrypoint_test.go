@@ -1,11 +1,41 @@
 package main
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 )
 
 func TestWithEmptyArgs(t *testing.T) {
-	assert.NotEqual(t, 0, entrypoint(parseArgs([]string{})))
+	assert.NotEqual(t, 0, entrypoint(mustParseArgs(t, []string{})))
+}
+
+func TestEntrypointSubCommandTask(t *testing.T) {
+	t.Run("task defined", func(t *testing.T) {
+		dir := chdirDocument(t, map[string]string{
+			"docstak.md": "# project\n\n## list\n\n```yaml:docstak.yml\nparams:\n  - name: target\n```\n\n```sh\necho \"$target\" > listed.txt\n```\n",
+		})
+
+		assert.Equal(t, 0, entrypoint(mustParseArgs(t, []string{"list", "target=docs"})))
+		b, err := os.ReadFile(filepath.Join(dir, "listed.txt"))
+		assert.NoError(t, err)
+		assert.Equal(t, "docs\n", string(b))
+
+		// The flag is always the feature.
+		assert.NoError(t, os.Remove(filepath.Join(dir, "listed.txt")))
+		assert.Equal(t, 0, entrypoint(mustParseArgs(t, []string{"--list"})))
+		assert.NoFileExists(t, filepath.Join(dir, "listed.txt"))
+	})
+
+	t.Run("task not defined", func(t *testing.T) {
+		dir := chdirDocument(t, map[string]string{
+			"docstak.md": "# project\n\n## build\n\n```sh\ntouch built.txt\n```\n",
+		})
+
+		assert.Equal(t, 0, entrypoint(mustParseArgs(t, []string{"list"})))
+		assert.NoFileExists(t, filepath.Join(dir, "built.txt"))
+		assert.NotEqual(t, 0, entrypoint(mustParseArgs(t, []string{"list", "target=docs"})))
+	})
 }
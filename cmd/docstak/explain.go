@@ -0,0 +1,178 @@
+/*
+Copyright 2024 Kasai Kou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/cockroachdb/errors"
+	"github.com/kasaikou/markflow/app"
+	"github.com/kasaikou/markflow/docstak"
+	"github.com/kasaikou/markflow/docstak/condition"
+	"github.com/kasaikou/markflow/docstak/model"
+)
+
+type explainResult string
+
+const (
+	explainRun          explainResult = "run"
+	explainSkip         explainResult = "skip"
+	explainInsufficient explainResult = "insufficient"
+)
+
+type explainTask struct {
+	Call     string             `json:"task"`
+	Result   explainResult      `json:"result"`
+	Skips    []condition.Reason `json:"skips"`
+	Requires []condition.Reason `json:"requires"`
+}
+
+func explain(ctx context.Context, args parseArgResult) int {
+	logger := docstak.GetLogger(ctx)
+	if len(args.Cmds) < 1 {
+		logger.Error("set no task")
+		return -1
+	}
+
+	document, success := app.NewLocalDocument(ctx)
+	if !success {
+		return -1
+	}
+
+	tasks, err := explainTasks(ctx, document.Document, args.Cmds, args.Params)
+	if err != nil {
+		logger.Error("cannot explain tasks", slog.Any("error", err))
+		return -1
+	}
+
+	if *args.JSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(tasks)
+	} else {
+		err = writeExplain(os.Stdout, tasks)
+	}
+
+	if err != nil {
+		logger.Error("cannot output explanation", slog.Any("error", err))
+		return -1
+	}
+
+	return 0
+}
+
+// Checks the conditions of the tasks and their dependencies in the same order as running them, without running anything.
+// The dependencies come before the tasks depending on them.
+func explainTasks(ctx context.Context, document model.Document, calls []string, explainParams map[string]map[string]string) ([]explainTask, error) {
+	for _, call := range calls {
+		if _, exist := document.Tasks[call]; !exist {
+			if suggestion := model.SuggestClosest(call, document.SortedCalls()); suggestion != "" {
+				return nil, errors.Errorf("cannot found task '%s' (did you mean '%s'?)", call, suggestion)
+			}
+			return nil, errors.Errorf("cannot found task '%s'", call)
+		}
+	}
+
+	// The dependencies are defined, and not circulated, when the document is loaded.
+	ordered := []string{}
+	visited := map[string]struct{}{}
+	var visit func(call string)
+	visit = func(call string) {
+		if _, exist := visited[call]; exist {
+			return
+		}
+		visited[call] = struct{}{}
+
+		for _, depend := range document.Tasks[call].DependTasks {
+			visit(depend)
+		}
+		ordered = append(ordered, call)
+	}
+	for _, call := range calls {
+		visit(call)
+	}
+
+	tasks := make([]explainTask, 0, len(ordered))
+	for _, call := range ordered {
+		task := document.Tasks[call]
+
+		params, err := task.ResolveParams(explainParams[call])
+		if err != nil {
+			return nil, errors.WithMessagef(err, "invalid parameters of task '%s'", call)
+		}
+
+		skip, skips := condition.NewSkipsFromDocumentTask(&task, condition.SkipsOptParams(params)).Test(ctx, condition.TestOption{})
+		sufficient, requires := condition.NewRequiresFromDocumentTask(&task).Test(ctx, condition.TestOption{})
+
+		result := explainRun
+		if skip {
+			result = explainSkip
+		} else if !sufficient {
+			result = explainInsufficient
+		}
+
+		tasks = append(tasks, explainTask{
+			Call:     call,
+			Result:   result,
+			Skips:    skips,
+			Requires: requires,
+		})
+	}
+
+	return tasks, nil
+}
+
+func writeExplain(w io.Writer, tasks []explainTask) error {
+	for _, task := range tasks {
+		var summary string
+		switch task.Result {
+		case explainSkip:
+			summary = "will be skipped"
+		case explainInsufficient:
+			summary = "will fail because the requirements are insufficient"
+		default:
+			summary = "will run"
+		}
+
+		if _, err := fmt.Fprintf(w, "%s: %s\n", task.Call, summary); err != nil {
+			return err
+		}
+
+		if len(task.Skips)+len(task.Requires) == 0 {
+			if _, err := fmt.Fprintln(w, "  no skip or require rules"); err != nil {
+				return err
+			}
+		}
+
+		for _, reason := range append(task.Skips[:len(task.Skips):len(task.Skips)], task.Requires...) {
+			mark := "not satisfied"
+			if reason.Satisfied {
+				mark = "satisfied"
+			}
+			if _, err := fmt.Fprintf(w, "  [%s] %s\n", mark, reason); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
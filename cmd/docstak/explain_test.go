@@ -0,0 +1,89 @@
+/*
+Copyright 2024 Kasai Kou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/kasaikou/markflow/app"
+	"github.com/kasaikou/markflow/docstak"
+	"github.com/kasaikou/markflow/docstak/condition"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExplainTasks(t *testing.T) {
+	files := map[string]string{
+		"docstak.md": "# project\n\n" +
+			"## build\n\n```yaml:docstak.yml\nskips:\n  file:\n    exist: [dist/*.js]\n```\n\n```sh\nexit 1\n```\n\n" +
+			"## deploy\n\n```yaml:docstak.yml\nprevious: [build]\nrequires:\n  file:\n    exist: [.env.deploy]\n```\n\n```sh\nexit 1\n```\n\n" +
+			"## test\n\n```sh\nexit 1\n```\n",
+		"dist/main.js": "",
+	}
+	chdirDocument(t, files)
+
+	ctx := docstak.WithLogger(context.Background(), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	document, success := app.NewLocalDocument(ctx)
+	if !assert.True(t, success) {
+		return
+	}
+
+	// The dependency 'build' is explained before 'deploy' as it runs first.
+	tasks, err := explainTasks(ctx, document.Document, []string{"deploy", "test"}, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, []explainTask{
+		{
+			Call:   "build",
+			Result: explainSkip,
+			Skips: []condition.Reason{
+				{Rule: "skips.file.exist", Kind: condition.ReasonFileExist, Satisfied: true, Patterns: []string{"dist/*.js"}, Matched: []string{"dist/main.js"}},
+			},
+		},
+		{
+			Call:   "deploy",
+			Result: explainInsufficient,
+			Requires: []condition.Reason{
+				{Rule: "requires.file.exist", Kind: condition.ReasonFileExist, Patterns: []string{".env.deploy"}, Matched: []string{}},
+			},
+		},
+		{Call: "test", Result: explainRun},
+	}, tasks)
+
+	buf := bytes.Buffer{}
+	assert.NoError(t, writeExplain(&buf, tasks))
+	assert.Equal(t, "build: will be skipped\n"+
+		"  [satisfied] skips.file.exist: 1 files matched with 'dist/*.js' exist\n"+
+		"deploy: will fail because the requirements are insufficient\n"+
+		"  [not satisfied] requires.file.exist: no file matched with '.env.deploy'\n"+
+		"test: will run\n"+
+		"  no skip or require rules\n", buf.String())
+
+	// The task both called and depended on is explained once.
+	tasks, err = explainTasks(ctx, document.Document, []string{"deploy", "build"}, nil)
+	if assert.NoError(t, err) && assert.Len(t, tasks, 2) {
+		assert.Equal(t, []string{"build", "deploy"}, []string{tasks[0].Call, tasks[1].Call})
+	}
+
+	_, err = explainTasks(ctx, document.Document, []string{"tset"}, nil)
+	assert.ErrorContains(t, err, "did you mean 'test'?")
+}
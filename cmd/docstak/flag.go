@@ -16,32 +16,155 @@ limitations under the License.
 
 package main
 
-import "github.com/spf13/pflag"
+import (
+	"io"
+	"regexp"
+	"runtime"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/spf13/pflag"
+)
 
 type parseArgResult struct {
-	Verbose *bool    `json:"verbose,omitempty"`
-	Quiet   *bool    `json:"quiet,omitempty"`
-	Help    *bool    `json:"help,omitempty"`
-	DryRun  *bool    `json:"dry_run,omitempty"`
-	Cmds    []string `json:"cmds,omitempty"`
+	Verbose   *bool          `json:"verbose,omitempty"`
+	Quiet     *bool          `json:"quiet,omitempty"`
+	Help      *bool          `json:"help,omitempty"`
+	DryRun    *bool          `json:"dry_run,omitempty"`
+	Jobs      *int           `json:"jobs,omitempty"`
+	KeepGoing *bool          `json:"keep_going,omitempty"`
+	List      *bool          `json:"list,omitempty"`
+	Tree      *bool          `json:"tree,omitempty"`
+	JSON      *bool          `json:"json,omitempty"`
+	Graph     *bool          `json:"graph,omitempty"`
+	Format    *string        `json:"format,omitempty"`
+	Schema    *bool          `json:"schema,omitempty"`
+	Explain   *bool          `json:"explain,omitempty"`
+	Timeout   *time.Duration `json:"timeout,omitempty"`
+	// Paths of the report files.
+	ReportJUnit *string `json:"report_junit,omitempty"`
+	ReportJSON  *string `json:"report_json,omitempty"`
+	// Sub-command given as the first argument, which a task with the same name takes precedence over.
+	SubCommand string   `json:"sub_command,omitempty"`
+	Cmds       []string `json:"cmds,omitempty"`
+	// Parameters of the tasks set with 'key=value' after the task name.
+	Params map[string]map[string]string `json:"params,omitempty"`
+	// Arguments after '--' passed to the called tasks.
+	ExtraArgs []string `json:"extra_args,omitempty"`
 }
 
-func parseArgs(args []string) parseArgResult {
+// Returns the error when the arguments are invalid.
+// Parameters can be given only to the tasks in the arguments, so the ones of the dependencies are set by calling them explicitly.
+func parseArgs(args []string) (parseArgResult, error) {
 
-	pflag := pflag.NewFlagSet("", pflag.ExitOnError)
+	pflag := pflag.NewFlagSet("", pflag.ContinueOnError)
+	// The error is returned instead of being written with the usage.
+	pflag.SetOutput(io.Discard)
 	verbose := pflag.BoolP("verbose", "v", true, "Be verbose (default).")
 	quiet := pflag.BoolP("quiet", "q", false, "Output only error message with stderr.")
 	help := pflag.BoolP("help", "h", false, "Output help information.")
 	dryRun := pflag.Bool("dry-run", false, "Output the operation configuration but do not execute.")
+	jobs := pflag.IntP("jobs", "j", runtime.NumCPU(), "Maximum number of scripts running at the same time.")
+	keepGoing := pflag.BoolP("keep-going", "k", false, "Continue tasks which do not depend on a failed task.")
+	list := pflag.Bool("list", false, "Output tasks defined in the document (same as 'list' sub-command).")
+	tree := pflag.Bool("tree", false, "Output tasks as a heading tree (with --list).")
+	jsonOutput := pflag.Bool("json", false, "Output in JSON format (with --list).")
+	graph := pflag.Bool("graph", false, "Output the task dependency graph (same as 'graph' sub-command).")
+	format := pflag.String("format", "mermaid", "Graph format, 'mermaid' or 'dot' (with --graph).")
+	schema := pflag.Bool("schema", false, "Output JSON Schema of docstak.yml blocks (same as 'schema' sub-command).")
+	explain := pflag.Bool("explain", false, "Output why the tasks and their dependencies will be skipped or run without running them (same as 'explain' sub-command).")
+	reportJUnit := pflag.String("report-junit", "", "Write the results of the tasks as JUnit XML to the path.")
+	reportJSON := pflag.String("report-json", "", "Write the results of the tasks as JSON to the path.")
+	timeout := pflag.Duration("timeout", 0, "Time limit of each code block without timeout in the document (0 for no limit).")
+
+	if err := pflag.Parse(args); err != nil {
+		return parseArgResult{}, err
+	}
+	if *jobs < 1 {
+		return parseArgResult{}, errors.Errorf("number of jobs must be positive (have: %d)", *jobs)
+	}
 
-	pflag.Parse(args)
 	cmds := pflag.Args()
 
-	return parseArgResult{
-		Verbose: verbose,
-		Quiet:   quiet,
-		Help:    help,
-		DryRun:  dryRun,
-		Cmds:    cmds,
+	var extraArgs []string
+	if dash := pflag.ArgsLenAtDash(); dash >= 0 {
+		extraArgs = cmds[dash:]
+		cmds = cmds[:dash]
+	}
+
+	// The parameters following the sub-command are kept for the task with the same name.
+	cmds, params, err := splitTaskParams(cmds)
+	if err != nil {
+		return parseArgResult{}, err
 	}
+
+	result := parseArgResult{
+		Verbose:     verbose,
+		Quiet:       quiet,
+		Help:        help,
+		DryRun:      dryRun,
+		Jobs:        jobs,
+		KeepGoing:   keepGoing,
+		List:        list,
+		Tree:        tree,
+		JSON:        jsonOutput,
+		Graph:       graph,
+		Format:      format,
+		Schema:      schema,
+		Explain:     explain,
+		Timeout:     timeout,
+		ReportJUnit: reportJUnit,
+		ReportJSON:  reportJSON,
+		Cmds:        cmds,
+		Params:      params,
+		ExtraArgs:   extraArgs,
+	}
+
+	if len(cmds) > 0 {
+		if enable, exist := result.subCommands()[cmds[0]]; exist {
+			*enable = true
+			result.SubCommand = cmds[0]
+			result.Cmds = cmds[1:]
+		}
+	}
+
+	return result, nil
+}
+
+// Sub-commands are aliases of the feature flags.
+func (args *parseArgResult) subCommands() map[string]*bool {
+	return map[string]*bool{
+		"list":    args.List,
+		"graph":   args.Graph,
+		"schema":  args.Schema,
+		"explain": args.Explain,
+	}
+}
+
+var taskParamRule = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*)=(.*)$`)
+
+// Split 'key=value' arguments from task names, and bind them to the preceding task.
+func splitTaskParams(args []string) (calls []string, params map[string]map[string]string, err error) {
+	for _, arg := range args {
+		matched := taskParamRule.FindStringSubmatch(arg)
+		if matched == nil {
+			calls = append(calls, arg)
+			continue
+		}
+
+		if len(calls) == 0 {
+			return nil, nil, errors.Errorf("parameter '%s' must follow the task name", arg)
+		}
+
+		call := calls[len(calls)-1]
+		if params == nil {
+			params = map[string]map[string]string{}
+		}
+		if params[call] == nil {
+			params[call] = map[string]string{}
+		}
+		params[call][matched[1]] = matched[2]
+	}
+
+	return calls, params, nil
 }
@@ -18,24 +18,136 @@ package main
 
 import (
 	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
 
 func P[T any](val T) *T { return &val }
 
+func mustParseArgs(t *testing.T, args []string) parseArgResult {
+	t.Helper()
+	result, err := parseArgs(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+// Writes the files into a temporary directory, and changes the working directory to it until the test ends.
+// Returns the directory with the symbolic links resolved, which the scripts see as their working directory.
+func chdirDocument(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, content := range files {
+		filename := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	return dir
+}
+
 func TestFlag(t *testing.T) {
-	resultArgs := parseArgs([]string{"-v", "-q", "fmt", "test"})
+	resultArgs := mustParseArgs(t, []string{"-v", "-q", "fmt", "test"})
 	expect := parseArgResult{
-		Verbose: P(true),
-		Quiet:   P(true),
-		Help:    P(false),
-		DryRun:  P(false),
-		Cmds:    []string{"fmt", "test"},
+		Verbose:     P(true),
+		Quiet:       P(true),
+		Help:        P(false),
+		DryRun:      P(false),
+		Jobs:        P(runtime.NumCPU()),
+		KeepGoing:   P(false),
+		List:        P(false),
+		Tree:        P(false),
+		JSON:        P(false),
+		Graph:       P(false),
+		Format:      P("mermaid"),
+		Schema:      P(false),
+		Explain:     P(false),
+		Timeout:     P(time.Duration(0)),
+		ReportJUnit: P(""),
+		ReportJSON:  P(""),
+		Cmds:        []string{"fmt", "test"},
 	}
 
 	resultJson, _ := json.MarshalIndent(resultArgs, "", "  ")
 	expectJson, _ := json.MarshalIndent(expect, "", "  ")
 	assert.Equal(t, string(expectJson), string(resultJson))
 }
+
+func TestFlagJobs(t *testing.T) {
+	resultArgs := mustParseArgs(t, []string{"-j", "2", "test"})
+	assert.Equal(t, 2, *resultArgs.Jobs)
+	assert.Equal(t, []string{"test"}, resultArgs.Cmds)
+
+	resultArgs = mustParseArgs(t, []string{"--jobs=4", "test"})
+	assert.Equal(t, 4, *resultArgs.Jobs)
+
+	for _, jobs := range []string{"-j0", "--jobs=-3"} {
+		_, err := parseArgs([]string{jobs, "test"})
+		assert.Error(t, err, jobs)
+	}
+}
+
+func TestFlagTimeout(t *testing.T) {
+	resultArgs := mustParseArgs(t, []string{"--timeout", "10m", "ci"})
+	assert.Equal(t, 10*time.Minute, *resultArgs.Timeout)
+	assert.Equal(t, []string{"ci"}, resultArgs.Cmds)
+}
+
+func TestFlagSubCommand(t *testing.T) {
+	resultArgs := mustParseArgs(t, []string{"list", "--json"})
+	assert.True(t, *resultArgs.List)
+	assert.True(t, *resultArgs.JSON)
+	assert.Equal(t, "list", resultArgs.SubCommand)
+	assert.Empty(t, resultArgs.Cmds)
+
+	resultArgs = mustParseArgs(t, []string{"--list"})
+	assert.True(t, *resultArgs.List)
+	assert.Empty(t, resultArgs.SubCommand)
+}
+
+func TestFlagTaskParams(t *testing.T) {
+	resultArgs := mustParseArgs(t, []string{"deploy", "env=staging", "region=", "test", "--", "--extra", "args"})
+	assert.Equal(t, []string{"deploy", "test"}, resultArgs.Cmds)
+	assert.Equal(t, map[string]map[string]string{
+		"deploy": {"env": "staging", "region": ""},
+	}, resultArgs.Params)
+	assert.Equal(t, []string{"--extra", "args"}, resultArgs.ExtraArgs)
+}
+
+func TestFlagUnknown(t *testing.T) {
+	_, err := parseArgs([]string{"--unknown", "test"})
+	assert.EqualError(t, err, "unknown flag: --unknown")
+}
+
+func TestFlagTaskParamsWithoutTask(t *testing.T) {
+	_, err := parseArgs([]string{"env=staging", "deploy"})
+	assert.EqualError(t, err, "parameter 'env=staging' must follow the task name")
+}
+
+func TestSplitTaskParamsWithoutTask(t *testing.T) {
+	_, _, err := splitTaskParams([]string{"env=staging", "deploy"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2024 Kasai Kou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/kasaikou/markflow/app"
+	"github.com/kasaikou/markflow/docstak"
+	"github.com/kasaikou/markflow/docstak/model"
+)
+
+func graph(ctx context.Context, args parseArgResult) int {
+	logger := docstak.GetLogger(ctx)
+
+	document, success := app.NewLocalDocument(ctx)
+	if !success {
+		return -1
+	}
+
+	calls, err := graphCalls(document.Document, args.Cmds)
+	if err != nil {
+		logger.Error("cannot resolve graph tasks", slog.Any("error", err))
+		return -1
+	}
+
+	switch *args.Format {
+	case "mermaid":
+		err = writeGraphMermaid(os.Stdout, document.Document, calls)
+	case "dot":
+		err = writeGraphDot(os.Stdout, document.Document, calls)
+	default:
+		logger.Error("unknown graph format", slog.String("format", *args.Format))
+		return -1
+	}
+
+	if err != nil {
+		logger.Error("cannot output graph", slog.Any("error", err))
+		return -1
+	}
+
+	return 0
+}
+
+// Returns tasks reachable from the roots in the document order.
+// All tasks are returned when no roots are given.
+func graphCalls(document model.Document, roots []string) ([]string, error) {
+	sorted := document.SortedCalls()
+	if len(roots) == 0 {
+		return sorted, nil
+	}
+
+	reachable := map[string]struct{}{}
+	for i := 0; i < len(roots); i++ {
+		task, exist := document.Tasks[roots[i]]
+		if !exist {
+			return nil, errors.Errorf("cannot found task '%s'", roots[i])
+		}
+
+		if _, exist := reachable[roots[i]]; exist {
+			continue
+		}
+
+		reachable[roots[i]] = struct{}{}
+		roots = append(roots, task.DependTasks...)
+	}
+
+	calls := make([]string, 0, len(reachable))
+	for _, call := range sorted {
+		if _, exist := reachable[call]; exist {
+			calls = append(calls, call)
+		}
+	}
+
+	return calls, nil
+}
+
+var mermaidLabelReplacer = strings.NewReplacer(`"`, "#quot;")
+
+func writeGraphMermaid(w io.Writer, document model.Document, calls []string) error {
+	ids := make(map[string]string, len(calls))
+	for i := range calls {
+		ids[calls[i]] = fmt.Sprintf("task%d", i)
+	}
+
+	builder := strings.Builder{}
+	builder.WriteString("flowchart LR\n")
+	for i := range calls {
+		fmt.Fprintf(&builder, "    %s[\"%s\"]\n", ids[calls[i]], mermaidLabelReplacer.Replace(calls[i]))
+	}
+
+	// Edges are directed from the dependent task to the task depending on it.
+	for i := range calls {
+		for _, depend := range document.Tasks[calls[i]].DependTasks {
+			if id, exist := ids[depend]; exist {
+				fmt.Fprintf(&builder, "    %s --> %s\n", id, ids[calls[i]])
+			}
+		}
+	}
+
+	_, err := io.WriteString(w, builder.String())
+	return err
+}
+
+var dotStringReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+func writeGraphDot(w io.Writer, document model.Document, calls []string) error {
+	included := make(map[string]struct{}, len(calls))
+	for i := range calls {
+		included[calls[i]] = struct{}{}
+	}
+
+	builder := strings.Builder{}
+	builder.WriteString("digraph docstak {\n")
+	builder.WriteString("  rankdir=LR;\n")
+	for i := range calls {
+		fmt.Fprintf(&builder, "  \"%s\";\n", dotStringReplacer.Replace(calls[i]))
+	}
+
+	// Edges are directed from the dependent task to the task depending on it.
+	for i := range calls {
+		for _, depend := range document.Tasks[calls[i]].DependTasks {
+			if _, exist := included[depend]; exist {
+				fmt.Fprintf(&builder, "  \"%s\" -> \"%s\";\n", dotStringReplacer.Replace(depend), dotStringReplacer.Replace(calls[i]))
+			}
+		}
+	}
+	builder.WriteString("}\n")
+
+	_, err := io.WriteString(w, builder.String())
+	return err
+}
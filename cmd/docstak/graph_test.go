@@ -0,0 +1,130 @@
+/*
+Copyright 2024 Kasai Kou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kasaikou/markflow/docstak/model"
+	"github.com/stretchr/testify/assert"
+)
+
+var updateGolden = flag.Bool("update", false, "Update golden files in testdata.")
+
+func graphTestDocument() model.Document {
+	return model.Document{
+		Tasks: map[string]model.DocumentTask{
+			"download": {Index: 0, Call: "download"},
+			"test": {
+				Index:       1,
+				Call:        "test",
+				DependTasks: []string{"download"},
+			},
+			"fmt": {Index: 2, Call: "fmt"},
+			"ci": {
+				Index:       3,
+				Call:        "ci",
+				DependTasks: []string{"ci/fmt", "ci/coverage-test"},
+			},
+			"ci/fmt": {Index: 4, Call: "ci/fmt"},
+			"ci/coverage-test": {
+				Index:       5,
+				Call:        "ci/coverage-test",
+				DependTasks: []string{"download"},
+			},
+			"say \"hello\"": {Index: 6, Call: "say \"hello\""},
+		},
+	}
+}
+
+func assertGolden(t *testing.T, name string, actual []byte) {
+	t.Helper()
+	filename := filepath.Join("testdata", name)
+
+	if *updateGolden {
+		if !assert.NoError(t, os.WriteFile(filename, actual, 0644)) {
+			return
+		}
+	}
+
+	expect, err := os.ReadFile(filename)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, string(expect), string(actual))
+}
+
+func TestGraph(t *testing.T) {
+	tests := []struct {
+		Golden string
+		Roots  []string
+		Write  func(buffer *bytes.Buffer, document model.Document, calls []string) error
+	}{
+		{
+			Golden: "graph_all.mermaid.golden",
+			Write: func(buffer *bytes.Buffer, document model.Document, calls []string) error {
+				return writeGraphMermaid(buffer, document, calls)
+			},
+		},
+		{
+			Golden: "graph_ci.mermaid.golden",
+			Roots:  []string{"ci"},
+			Write: func(buffer *bytes.Buffer, document model.Document, calls []string) error {
+				return writeGraphMermaid(buffer, document, calls)
+			},
+		},
+		{
+			Golden: "graph_all.dot.golden",
+			Write: func(buffer *bytes.Buffer, document model.Document, calls []string) error {
+				return writeGraphDot(buffer, document, calls)
+			},
+		},
+		{
+			Golden: "graph_ci.dot.golden",
+			Roots:  []string{"ci"},
+			Write: func(buffer *bytes.Buffer, document model.Document, calls []string) error {
+				return writeGraphDot(buffer, document, calls)
+			},
+		},
+	}
+
+	document := graphTestDocument()
+	for _, test := range tests {
+		t.Run(test.Golden, func(t *testing.T) {
+			calls, err := graphCalls(document, test.Roots)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			buffer := bytes.Buffer{}
+			if !assert.NoError(t, test.Write(&buffer, document, calls)) {
+				return
+			}
+			assertGolden(t, test.Golden, buffer.Bytes())
+		})
+	}
+}
+
+func TestGraphUnknownTask(t *testing.T) {
+	_, err := graphCalls(graphTestDocument(), []string{"unknown"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2024 Kasai Kou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/kasaikou/markflow/app"
+	"github.com/kasaikou/markflow/docstak"
+	"github.com/kasaikou/markflow/docstak/model"
+)
+
+type listTask struct {
+	Call         string   `json:"call"`
+	Description  string   `json:"description,omitempty"`
+	HeadingLevel int      `json:"heading_level"`
+	DependTasks  []string `json:"depend_tasks,omitempty"`
+	HasScripts   bool     `json:"has_scripts"`
+}
+
+func list(ctx context.Context, args parseArgResult) int {
+	logger := docstak.GetLogger(ctx)
+
+	document, success := app.NewLocalDocument(ctx)
+	if !success {
+		return -1
+	}
+
+	tasks := listTasks(document.Document)
+
+	var err error
+	if *args.JSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(tasks)
+	} else {
+		err = writeListTable(os.Stdout, tasks, *args.Tree)
+	}
+
+	if err != nil {
+		logger.Error("cannot output task list", slog.Any("error", err))
+		return -1
+	}
+
+	return 0
+}
+
+func listTasks(document model.Document) []listTask {
+	calls := document.SortedCalls()
+	tasks := make([]listTask, 0, len(calls))
+
+	for _, call := range calls {
+		task := document.Tasks[call]
+		description, _, _ := strings.Cut(task.Description, "\n")
+		tasks = append(tasks, listTask{
+			Call:         task.Call,
+			Description:  description,
+			HeadingLevel: task.HeadingLevel,
+			DependTasks:  task.DependTasks,
+			HasScripts:   len(task.Scripts) > 0,
+		})
+	}
+
+	return tasks
+}
+
+func writeListTable(w io.Writer, tasks []listTask, tree bool) error {
+
+	// Indent task names with the heading level in tree view.
+	baseLevel := -1
+	for i := range tasks {
+		if baseLevel < 0 || tasks[i].HeadingLevel < baseLevel {
+			baseLevel = tasks[i].HeadingLevel
+		}
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "TASK\tSCRIPTS\tPREVIOUS\tDESCRIPTION")
+	for i := range tasks {
+		call := tasks[i].Call
+		if tree {
+			call = strings.Repeat("  ", tasks[i].HeadingLevel-baseLevel) + call
+		}
+
+		scripts := "no"
+		if tasks[i].HasScripts {
+			scripts = "yes"
+		}
+
+		previous := strings.Join(tasks[i].DependTasks, ", ")
+		if previous == "" {
+			previous = "-"
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", call, scripts, previous, tasks[i].Description)
+	}
+
+	return tw.Flush()
+}
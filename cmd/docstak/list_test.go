@@ -0,0 +1,67 @@
+/*
+Copyright 2024 Kasai Kou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/kasaikou/markflow/docstak/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListTasks(t *testing.T) {
+	document := model.Document{
+		Tasks: map[string]model.DocumentTask{
+			"ci": {
+				Index:        0,
+				HeadingLevel: 2,
+				Call:         "ci",
+				Description:  "Running on CI.\n\nMore details.",
+				DependTasks:  []string{"ci/fmt"},
+			},
+			"ci/fmt": {
+				Index:        1,
+				HeadingLevel: 3,
+				Call:         "ci/fmt",
+				Scripts:      []model.DocumentTaskScript{{Script: "gofmt -l ."}},
+			},
+		},
+	}
+
+	tasks := listTasks(document)
+	assert.Equal(t, []listTask{
+		{Call: "ci", Description: "Running on CI.", HeadingLevel: 2, DependTasks: []string{"ci/fmt"}},
+		{Call: "ci/fmt", HeadingLevel: 3, HasScripts: true},
+	}, tasks)
+
+	buffer := bytes.Buffer{}
+	assert.NoError(t, writeListTable(&buffer, tasks, false))
+	assert.Equal(t, ""+
+		"TASK    SCRIPTS  PREVIOUS  DESCRIPTION\n"+
+		"ci      no       ci/fmt    Running on CI.\n"+
+		"ci/fmt  yes      -         \n",
+		buffer.String())
+
+	buffer.Reset()
+	assert.NoError(t, writeListTable(&buffer, tasks, true))
+	assert.Equal(t, ""+
+		"TASK      SCRIPTS  PREVIOUS  DESCRIPTION\n"+
+		"ci        no       ci/fmt    Running on CI.\n"+
+		"  ci/fmt  yes      -         \n",
+		buffer.String())
+}
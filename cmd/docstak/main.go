@@ -16,8 +16,17 @@ limitations under the License.
 
 package main
 
-import "os"
+import (
+	"fmt"
+	"os"
+)
 
 func main() {
-	os.Exit(entrypoint(parseArgs(os.Args[1:])))
+	args, err := parseArgs(os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	os.Exit(entrypoint(args))
 }
@@ -0,0 +1,263 @@
+/*
+Copyright 2024 Kasai Kou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/kasaikou/markflow/docstak"
+)
+
+// Lines of stdout and stderr of the tasks captured by the console scanners for the reports.
+type taskOutputs struct {
+	mu      sync.Mutex
+	outputs map[string]*taskOutput
+}
+
+type taskOutput struct {
+	stdout lockedBuffer
+	stderr lockedBuffer
+}
+
+// Buffer written by the code blocks running in parallel.
+type lockedBuffer struct {
+	mu     sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (lb *lockedBuffer) Write(p []byte) (int, error) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	return lb.buffer.Write(p)
+}
+
+func (lb *lockedBuffer) String() string {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	return lb.buffer.String()
+}
+
+func newTaskOutputs() *taskOutputs {
+	return &taskOutputs{outputs: map[string]*taskOutput{}}
+}
+
+// Returns the output of the task, which is created if it does not exist.
+func (to *taskOutputs) get(call string) *taskOutput {
+	to.mu.Lock()
+	defer to.mu.Unlock()
+
+	output, exist := to.outputs[call]
+	if !exist {
+		output = &taskOutput{}
+		to.outputs[call] = output
+	}
+
+	return output
+}
+
+func (to *taskOutputs) stdout(call string) string { return to.get(call).stdout.String() }
+func (to *taskOutputs) stderr(call string) string { return to.get(call).stderr.String() }
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Errors   int             `xml:"errors,attr"`
+	Skipped  int             `xml:"skipped,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut *junitOutput  `xml:"system-out,omitempty"`
+	SystemErr *junitOutput  `xml:"system-err,omitempty"`
+}
+
+// Output written as CDATA to keep line breaks readable.
+type junitOutput struct {
+	Text string `xml:",cdata"`
+}
+
+// Escape sequences like ANSI colors in the outputs of the tasks.
+var escapeSequenceRule = regexp.MustCompile(`\x1b(\[[0-?]*[ -/]*[@-~]|[@-Z\\-_])`)
+
+// Removes the escape sequences and the characters not allowed in XML 1.0, which the CDATA cannot escape.
+func newJUnitOutput(text string) *junitOutput {
+	text = strings.Map(func(r rune) rune {
+		switch {
+		case r == '\t' || r == '\n' || r == '\r':
+			return r
+		case r < 0x20 || (0xD800 <= r && r <= 0xDFFF) || r == 0xFFFE || r == 0xFFFF:
+			return -1
+		default:
+			return r
+		}
+	}, escapeSequenceRule.ReplaceAllString(text, ""))
+
+	if text == "" {
+		return nil
+	}
+	return &junitOutput{Text: text}
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr,omitempty"`
+}
+
+// Writes the results as JUnit XML, in which each task is a test case.
+// Failed tasks are failures, cancelled tasks are errors, and skipped or not run tasks are skipped.
+func writeJUnitReport(w io.Writer, name string, results []docstak.TaskResult, outputs *taskOutputs) error {
+	suite := junitTestSuite{Name: name}
+	total := 0.0
+
+	for i := range results {
+		testCase := junitTestCase{
+			Name:      results[i].Call,
+			ClassName: name,
+			Time:      fmt.Sprintf("%.3f", results[i].Duration.Seconds()),
+			SystemOut: newJUnitOutput(outputs.stdout(results[i].Call)),
+			SystemErr: newJUnitOutput(outputs.stderr(results[i].Call)),
+		}
+
+		switch results[i].Status {
+		case docstak.TaskFailed:
+			suite.Failures++
+			testCase.Failure = &junitMessage{
+				Message: fmt.Sprintf("exited with code %d", results[i].ExitCode),
+				Type:    string(results[i].Status),
+			}
+		case docstak.TaskCancelled:
+			suite.Errors++
+			testCase.Error = &junitMessage{Message: "cancelled while running", Type: string(results[i].Status)}
+		case docstak.TaskSkipped:
+			suite.Skipped++
+			testCase.Skipped = &junitMessage{Message: results[i].Reason}
+		case docstak.TaskNotRun:
+			suite.Skipped++
+			testCase.Skipped = &junitMessage{Message: "not run because the dependent task failed or the execution was canceled"}
+		}
+
+		suite.Tests++
+		suite.Cases = append(suite.Cases, testCase)
+		total += results[i].Duration.Seconds()
+	}
+	suite.Time = fmt.Sprintf("%.3f", total)
+
+	suites := junitTestSuites{
+		Name:     name,
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Errors:   suite.Errors,
+		Skipped:  suite.Skipped,
+		Time:     suite.Time,
+		Suites:   []junitTestSuite{suite},
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(suites); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+type jsonReport struct {
+	ExitCode int              `json:"exit_code"`
+	Tasks    []jsonReportTask `json:"tasks"`
+}
+
+type jsonReportTask struct {
+	Call     string             `json:"task"`
+	Status   docstak.TaskStatus `json:"status"`
+	ExitCode int                `json:"exit_code"`
+	Duration float64            `json:"duration"` // In seconds.
+	Attempts int                `json:"attempts"`
+	Reason   string             `json:"reason,omitempty"`
+	Stdout   string             `json:"stdout"`
+	Stderr   string             `json:"stderr"`
+}
+
+func writeJSONReport(w io.Writer, result docstak.ExecuteResult, outputs *taskOutputs) error {
+	report := jsonReport{
+		ExitCode: result.ExitCode,
+		Tasks:    make([]jsonReportTask, 0, len(result.Tasks)),
+	}
+
+	for _, res := range result.Tasks {
+		report.Tasks = append(report.Tasks, jsonReportTask{
+			Call:     res.Call,
+			Status:   res.Status,
+			ExitCode: res.ExitCode,
+			Duration: res.Duration.Seconds(),
+			Attempts: res.Attempts,
+			Reason:   res.Reason,
+			Stdout:   outputs.stdout(res.Call),
+			Stderr:   outputs.stderr(res.Call),
+		})
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(report)
+}
+
+// Creates the file and writes the report to it.
+func writeReportFile(filename string, write func(w io.Writer) error) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2024 Kasai Kou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"testing"
+	"time"
+
+	"github.com/kasaikou/markflow/docstak"
+	"github.com/stretchr/testify/assert"
+)
+
+func reportTestResult() (docstak.ExecuteResult, *taskOutputs) {
+	result := docstak.ExecuteResult{
+		ExitCode: 1,
+		Tasks: []docstak.TaskResult{
+			{Call: "build", Status: docstak.TaskSucceeded, Duration: 1500 * time.Millisecond, Attempts: 1},
+			{Call: "cache", Status: docstak.TaskSkipped, Duration: 2 * time.Millisecond, Attempts: 1, Reason: "skip conditions are satisfied"},
+			{Call: "test", Status: docstak.TaskFailed, ExitCode: 1, Duration: 2 * time.Second, Attempts: 3},
+			{Call: "serve", Status: docstak.TaskCancelled, ExitCode: -1, Duration: 2 * time.Second, Attempts: 1},
+			{Call: "deploy", Status: docstak.TaskNotRun},
+		},
+	}
+
+	outputs := newTaskOutputs()
+	outputs.get("build").stdout.Write([]byte("go build ./...\n"))
+	outputs.get("test").stdout.Write([]byte("--- FAIL: TestA <flaky>\n"))
+	outputs.get("test").stderr.Write([]byte("exit status 1\n"))
+
+	return result, outputs
+}
+
+func TestWriteJUnitReport(t *testing.T) {
+	result, outputs := reportTestResult()
+	buffer := bytes.Buffer{}
+	if assert.NoError(t, writeJUnitReport(&buffer, "docstak", result.Tasks, outputs)) {
+		assertGolden(t, "report.junit.xml.golden", buffer.Bytes())
+	}
+}
+
+func TestWriteJUnitReportEscapeSequence(t *testing.T) {
+	result := docstak.ExecuteResult{
+		ExitCode: 1,
+		Tasks: []docstak.TaskResult{
+			{Call: "test", Status: docstak.TaskFailed, ExitCode: 1, Duration: time.Second, Attempts: 1},
+		},
+	}
+	outputs := newTaskOutputs()
+	outputs.get("test").stdout.Write([]byte("\x1b[31m--- FAIL: TestA\x1b[0m\n\x00\x07done\n"))
+
+	buffer := bytes.Buffer{}
+	if !assert.NoError(t, writeJUnitReport(&buffer, "docstak", result.Tasks, outputs)) {
+		return
+	}
+
+	suites := junitTestSuites{}
+	if assert.NoError(t, xml.Unmarshal(buffer.Bytes(), &suites)) && assert.Len(t, suites.Suites, 1) && assert.Len(t, suites.Suites[0].Cases, 1) {
+		assert.Equal(t, "--- FAIL: TestA\ndone\n", suites.Suites[0].Cases[0].SystemOut.Text)
+	}
+}
+
+func TestWriteJSONReport(t *testing.T) {
+	result, outputs := reportTestResult()
+	buffer := bytes.Buffer{}
+	if assert.NoError(t, writeJSONReport(&buffer, result, outputs)) {
+		assertGolden(t, "report.json.golden", buffer.Bytes())
+	}
+}
@@ -18,12 +18,15 @@ package main
 
 import (
 	"context"
+	"fmt"
+	"io"
 	"log/slog"
 	"os"
 	"os/signal"
 	"sync"
 	"syscall"
 
+	"github.com/cockroachdb/errors"
 	"github.com/kasaikou/markflow/app"
 	"github.com/kasaikou/markflow/cli"
 	"github.com/kasaikou/markflow/docstak"
@@ -33,6 +36,48 @@ import (
 )
 
 func run(ctx context.Context, args parseArgResult) int {
+	logger := docstak.GetLogger(ctx)
+
+	// Capture the outputs of the tasks only when the reports require them.
+	var outputs *taskOutputs
+	if *args.ReportJUnit != "" || *args.ReportJSON != "" {
+		outputs = newTaskOutputs()
+	}
+
+	result, executed := runTasks(ctx, args, outputs)
+	if !executed {
+		return result.ExitCode
+	}
+
+	// Output the summary after all outputs of the tasks are written.
+	if err := writeSummaryTable(os.Stdout, result.Tasks); err != nil {
+		logger.Error("cannot write summary", slog.Any("error", err))
+	}
+
+	if *args.ReportJUnit != "" {
+		err := writeReportFile(*args.ReportJUnit, func(w io.Writer) error {
+			return writeJUnitReport(w, "docstak", result.Tasks, outputs)
+		})
+		if err != nil {
+			logger.Error("cannot write JUnit report", slog.String("filepath", *args.ReportJUnit), slog.Any("error", err))
+		}
+	}
+
+	if *args.ReportJSON != "" {
+		err := writeReportFile(*args.ReportJSON, func(w io.Writer) error {
+			return writeJSONReport(w, result, outputs)
+		})
+		if err != nil {
+			logger.Error("cannot write JSON report", slog.String("filepath", *args.ReportJSON), slog.Any("error", err))
+		}
+	}
+
+	return result.ExitCode
+}
+
+// Executes the tasks and returns whether the execution is started.
+// The outputs of the tasks are captured to outputs unless it is nil.
+func runTasks(ctx context.Context, args parseArgResult, outputs *taskOutputs) (docstak.ExecuteResult, bool) {
 	cwWaiter := sync.WaitGroup{}
 	defer cwWaiter.Wait()
 	cw, _ := cli.NewConsoleWriter(os.Stdout, cli.TerminalAutoDetect(os.Stdout))
@@ -47,11 +92,11 @@ func run(ctx context.Context, args parseArgResult) int {
 	ctx = docstak.WithLogger(ctx, logger)
 	if len(args.Cmds) < 1 {
 		logger.Error("set no task")
-		return -1
+		return docstak.ExecuteResult{ExitCode: -1}, false
 	}
 	document, success := app.NewLocalDocument(ctx)
 	if !success {
-		return -1
+		return docstak.ExecuteResult{ExitCode: -1}, false
 	}
 
 	chDecoration := make(chan cli.ProcessOutputDecoration, len(cli.ProcessOutputDecorations))
@@ -81,31 +126,62 @@ func run(ctx context.Context, args parseArgResult) int {
 	defer sigWaiter.Wait()
 	defer cancel()
 
-	exit := docstak.ExecuteContext(ctx, document.Document,
+	executeOpts := []docstak.ExecuteOption{
 		docstak.ExecuteOptCalls(args.Cmds...),
+		docstak.ExecuteOptWorkers(*args.Jobs),
+		docstak.ExecuteOptKeepGoing(*args.KeepGoing),
+		docstak.ExecuteOptTimeout(*args.Timeout),
+		docstak.ExecuteOptArgs(args.ExtraArgs...),
+	}
+	for call, params := range args.Params {
+		executeOpts = append(executeOpts, docstak.ExecuteOptParams(call, params))
+	}
+
+	// The tasks are shared with the running executor, so the updated ones are applied after it returns.
+	updated := map[string]model.DocumentTask{}
+	updatedMu := sync.Mutex{}
+
+	executeOpts = append(executeOpts,
+		docstak.ExecuteOptTaskCheck(func(ctx context.Context, task model.DocumentTask) error {
+			params, _ := docstak.GetParams(ctx)
+			isSkip, reasons := condition.NewSkipsFromDocumentTask(&task, condition.SkipsOptParams(params)).Test(ctx, condition.TestOption{})
+			if isSkip {
+				reason := condition.JoinReasons(reasons, true)
+				logger.Info("task execute is not required", slog.String("task", task.Call), slog.String("reason", reason))
+				return docstak.SkipError(reason)
+			} else if len(reasons) > 0 {
+				logger.Info("task execute is required", slog.String("task", task.Call), slog.String("reason", condition.JoinReasons(reasons, false)))
+			}
+
+			sufficient, reasons := condition.NewRequiresFromDocumentTask(&task).Test(ctx, condition.TestOption{})
+			if !sufficient {
+				return errors.Errorf("task's require rules are insufficient: %s", condition.JoinReasons(reasons, false))
+			}
+
+			return nil
+		}),
 		docstak.ExecuteOptProcessExec(func(ctx context.Context, task model.DocumentTask, runner *srun.ScriptRunner) (int, error) {
 			decoration := <-chDecoration
 			defer func() {
 				chDecoration <- decoration
 			}()
 
-			skip := condition.NewSkipsFromDocumentTask(&task)
-			isSkip := skip.Test(ctx, condition.TestOption{})
-			if isSkip {
-				logger.Info("task execute is not required", slog.String("task", task.Call))
-				return 0, nil
+			// Label the output of retries with the attempt number.
+			label := task.Title
+			attempt, _ := docstak.GetAttempt(ctx)
+			if attempt > 1 {
+				label = fmt.Sprintf("%s#%d", task.Title, attempt)
 			}
 
-			sufficient := condition.NewRequiresFromDocumentTask(&task).Test(ctx, condition.TestOption{})
-			if !sufficient {
-				logger.Error("task's require rules are insufficient", slog.String("task", task.Call))
-				return -1, nil
-			}
-
-			stdOutScanner := cw.NewScanner(decoration.Stdout, "STDOUT", task.Title)
+			stdOutScanner := cw.NewScanner(decoration.Stdout, "STDOUT", label)
 			stdout, _ := runner.Stdout()
-			stderrScanner := cw.NewScanner(decoration.Stderr, "ERROUT", task.Title)
+			stderrScanner := cw.NewScanner(decoration.Stderr, "ERROUT", label)
 			stderr, _ := runner.Stderr()
+			if outputs != nil {
+				output := outputs.get(task.Call)
+				stdOutScanner.Capture(&output.stdout)
+				stderrScanner.Capture(&output.stderr)
+			}
 
 			wg := sync.WaitGroup{}
 			defer wg.Wait()
@@ -122,21 +198,38 @@ func run(ctx context.Context, args parseArgResult) int {
 				stderrScanner.Scan(stderr)
 			}()
 
-			logger.Info("task start", slog.String("task", task.Call))
-			exit, err := runner.RunContext(ctx)
-			logger.Info("task ended", slog.String("task", task.Call), slog.Int("exitCode", exit))
-
-			if err != nil {
-				return exit, err
+			// Show which code block is running when the task has several ones.
+			block, _ := docstak.GetScriptIndex(ctx)
+			taskAttrs := []any{slog.String("task", task.Call)}
+			if len(task.Scripts) > 1 {
+				taskAttrs = append(taskAttrs, slog.Int("block", block))
+			}
+			if task.Retry.Attempts > 1 {
+				taskAttrs = append(taskAttrs, slog.String("attempt", fmt.Sprintf("%d/%d", attempt, task.Retry.Attempts)))
 			}
 
-			skip.UpdateDocumentTask(ctx, &task)
-			document.Document.Tasks[task.Call] = task
+			logger.Info("task start", taskAttrs...)
+			exit, err := runner.RunContext(ctx)
+			logger.Info("task ended", append(taskAttrs, slog.Int("exitCode", exit))...)
 
-			return exit, nil
+			return exit, err
+		}),
+		// Update the hash after every code block succeeded so that a failed task is not regarded as up to date.
+		docstak.ExecuteOptTaskSucceeded(func(ctx context.Context, task model.DocumentTask) {
+			params, _ := docstak.GetParams(ctx)
+			condition.NewSkipsFromDocumentTask(&task, condition.SkipsOptParams(params)).UpdateDocumentTask(ctx, &task)
+
+			updatedMu.Lock()
+			defer updatedMu.Unlock()
+			updated[task.Call] = task
 		}),
 	)
 
+	result := docstak.ExecuteContext(ctx, document.Document, executeOpts...)
+	for call, task := range updated {
+		document.Document.Tasks[call] = task
+	}
+
 	document.SaveState(ctx)
-	return exit
+	return result, len(result.Tasks) > 0
 }
@@ -0,0 +1,176 @@
+/*
+Copyright 2024 Kasai Kou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunFromSubdirectory(t *testing.T) {
+	files := map[string]string{
+		"docstak.md": "# project\n\n" +
+			"## root\n\n```sh\npwd > pwd.txt\n```\n\n" +
+			"## web\n\n```yaml:docstak.yml\nworkdir: web\nenviron:\n  dotenv: [.env]\nrequires:\n  file:\n    exist: [package.json]\n```\n\n" +
+			"```sh\necho \"$NODE_ENV\" > env.txt\npwd > pwd.txt\n```\n",
+		"web/.env":         "NODE_ENV=production\n",
+		"web/package.json": "{}\n",
+		"web/src/.keep":    "",
+	}
+	dir := chdirDocument(t, files)
+	if err := os.Chdir(filepath.Join(dir, "web", "src")); err != nil {
+		t.Fatal(err)
+	}
+
+	if !assert.Equal(t, 0, entrypoint(mustParseArgs(t, []string{"root", "web"}))) {
+		return
+	}
+
+	readFile := func(name string) string {
+		b, err := os.ReadFile(filepath.Join(dir, name))
+		assert.NoError(t, err)
+		return strings.TrimSpace(string(b))
+	}
+
+	assert.Equal(t, dir, readFile("pwd.txt"))
+	assert.Equal(t, filepath.Join(dir, "web"), readFile("web/pwd.txt"))
+	assert.Equal(t, "production", readFile("web/env.txt"))
+}
+
+func TestRunEnvConditions(t *testing.T) {
+	document := "```yaml:docstak.yml\nenviron:\n  vars:\n    DOCSTAK_TEST_STAGE: dev\n```\n\n# project\n\n" +
+		"## deploy\n\n```yaml:docstak.yml\nrequires:\n  env:\n    set: [DOCSTAK_TEST_PROFILE]\n    equals:\n      DOCSTAK_TEST_STAGE: dev\n```\n\n" +
+		"```sh\necho \"$DOCSTAK_TEST_STAGE\" > deploy.txt\n```\n\n" +
+		"## notify\n\n```yaml:docstak.yml\nskips:\n  env:\n    set: [DOCSTAK_TEST_QUIET]\n```\n\n```sh\ntouch notify.txt\n```\n"
+	dir := chdirDocument(t, map[string]string{"docstak.md": document})
+
+	// Fails before running anything without the required variable.
+	assert.NotEqual(t, 0, entrypoint(mustParseArgs(t, []string{"deploy"})))
+	assert.NoFileExists(t, filepath.Join(dir, "deploy.txt"))
+
+	t.Setenv("DOCSTAK_TEST_PROFILE", "default")
+	t.Setenv("DOCSTAK_TEST_QUIET", "")
+	if !assert.Equal(t, 0, entrypoint(mustParseArgs(t, []string{"deploy", "notify"}))) {
+		return
+	}
+
+	content, err := os.ReadFile(filepath.Join(dir, "deploy.txt"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, "dev", strings.TrimSpace(string(content)))
+	}
+	assert.NoFileExists(t, filepath.Join(dir, "notify.txt"))
+}
+
+func TestRunSkipsCheckedOncePerTask(t *testing.T) {
+	// The first code block creates the file of the skip rule, which must not skip the second one.
+	document := "# project\n\n## build\n\n```yaml:docstak.yml\nskips:\n  file:\n    exist: [out.txt]\n```\n\n" +
+		"```sh\ntouch out.txt\n```\n\n```sh\ntouch second.txt\n```\n"
+	dir := chdirDocument(t, map[string]string{"docstak.md": document})
+
+	if !assert.Equal(t, 0, entrypoint(mustParseArgs(t, []string{"build"}))) {
+		return
+	}
+	assert.FileExists(t, filepath.Join(dir, "second.txt"))
+
+	// The whole task is skipped in the next run.
+	if err := os.Remove(filepath.Join(dir, "second.txt")); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 0, entrypoint(mustParseArgs(t, []string{"build"})))
+	assert.NoFileExists(t, filepath.Join(dir, "second.txt"))
+}
+
+func TestRunRetryNotSkipped(t *testing.T) {
+	// The failed attempt leaves the file of the skip rule behind, which must not skip the retry.
+	document := "# project\n\n## build\n\n```yaml:docstak.yml\nskips:\n  file:\n    exist: [out.txt]\nretry:\n  attempts: 2\n```\n\n" +
+		"```sh\necho >> count.txt\ntouch out.txt\nexit 1\n```\n\n" +
+		"## deploy\n\n```yaml:docstak.yml\nprevious: [build]\n```\n\n```sh\ntouch deployed.txt\n```\n"
+	dir := chdirDocument(t, map[string]string{"docstak.md": document})
+
+	assert.Equal(t, 1, entrypoint(mustParseArgs(t, []string{"deploy"})))
+	assert.NoFileExists(t, filepath.Join(dir, "deployed.txt"))
+
+	count, err := os.ReadFile(filepath.Join(dir, "count.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, "\n\n", string(count))
+}
+
+func TestRunMatrixUpdatesState(t *testing.T) {
+	// The instances of the matrix task succeed at the same time and update their hashes.
+	document := "# project\n\n## test\n\n```yaml:docstak.yml\nmatrix:\n  os: [linux, darwin]\n  arch: [amd64, arm64]\nskips:\n  file:\n    not-changed: [input.txt]\n```\n\n" +
+		"```sh\necho \"$os $arch\" >> runs.txt\n```\n"
+	dir := chdirDocument(t, map[string]string{"docstak.md": document, "input.txt": "input"})
+
+	if !assert.Equal(t, 0, entrypoint(mustParseArgs(t, []string{"-j", "4", "test"}))) {
+		return
+	}
+
+	// Every instance is skipped in the next run.
+	if err := os.Remove(filepath.Join(dir, "runs.txt")); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 0, entrypoint(mustParseArgs(t, []string{"-j", "4", "test"})))
+	assert.NoFileExists(t, filepath.Join(dir, "runs.txt"))
+}
+
+func TestRunDependencyParams(t *testing.T) {
+	document := "# project\n\n## build\n\n```yaml:docstak.yml\nparams:\n  - name: target\n    required: true\n```\n\n```sh\necho \"$target\" > target.txt\n```\n\n" +
+		"## deploy\n\n```yaml:docstak.yml\nprevious: [build]\n```\n\n```sh\ntouch deployed.txt\n```\n"
+	dir := chdirDocument(t, map[string]string{"docstak.md": document})
+
+	// The required parameter of the dependency cannot be given without calling it.
+	assert.NotEqual(t, 0, entrypoint(mustParseArgs(t, []string{"deploy"})))
+	assert.NoFileExists(t, filepath.Join(dir, "deployed.txt"))
+
+	assert.Equal(t, 0, entrypoint(mustParseArgs(t, []string{"build", "target=linux", "deploy"})))
+	b, err := os.ReadFile(filepath.Join(dir, "target.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, "linux\n", string(b))
+	assert.FileExists(t, filepath.Join(dir, "deployed.txt"))
+}
+
+func TestRunReportOutputs(t *testing.T) {
+	dir := chdirDocument(t, map[string]string{
+		"docstak.md": "# project\n\n## build\n\n```sh\necho first\nprintf 'second\\r\\n'\necho error >&2\nprintf last\n```\n",
+	})
+
+	if !assert.Equal(t, 0, entrypoint(mustParseArgs(t, []string{"--report-json", "report.json", "build"}))) {
+		return
+	}
+
+	b, err := os.ReadFile(filepath.Join(dir, "report.json"))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// The outputs are the same lines as the console shows.
+	report := struct {
+		Tasks []struct {
+			Stdout string `json:"stdout"`
+			Stderr string `json:"stderr"`
+		} `json:"tasks"`
+	}{}
+	if assert.NoError(t, json.Unmarshal(b, &report)) && assert.Len(t, report.Tasks, 1) {
+		assert.Equal(t, "first\nsecond\nlast\n", report.Tasks[0].Stdout)
+		assert.Equal(t, "error\n", report.Tasks[0].Stderr)
+	}
+}
@@ -0,0 +1,46 @@
+/*
+Copyright 2024 Kasai Kou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kasaikou/markflow/docstak"
+)
+
+func writeSummaryTable(w io.Writer, results []docstak.TaskResult) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "TASK\tSTATUS\tEXIT\tDURATION\tATTEMPTS")
+	for i := range results {
+		exit, duration, attempts := "-", "-", "-"
+		if results[i].Status != docstak.TaskNotRun {
+			exit = strconv.Itoa(results[i].ExitCode)
+			duration = results[i].Duration.Round(time.Millisecond).String()
+		}
+		if results[i].Attempts > 0 {
+			attempts = strconv.Itoa(results[i].Attempts)
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", results[i].Call, results[i].Status, exit, duration, attempts)
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,45 @@
+/*
+Copyright 2024 Kasai Kou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/kasaikou/markflow/docstak"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteSummaryTable(t *testing.T) {
+	results := []docstak.TaskResult{
+		{Call: "build", Status: docstak.TaskSucceeded, Duration: 1500 * time.Millisecond, Attempts: 1},
+		{Call: "test", Status: docstak.TaskFailed, ExitCode: 1, Duration: 2*time.Second + 1234*time.Microsecond, Attempts: 3},
+		{Call: "cache", Status: docstak.TaskSkipped, Duration: 3 * time.Millisecond, Attempts: 1, Reason: "skip conditions are satisfied"},
+		{Call: "ci", Status: docstak.TaskNotRun},
+	}
+
+	buffer := bytes.Buffer{}
+	assert.NoError(t, writeSummaryTable(&buffer, results))
+	assert.Equal(t, ""+
+		"TASK   STATUS     EXIT  DURATION  ATTEMPTS\n"+
+		"build  succeeded  0     1.5s      1\n"+
+		"test   failed     1     2.001s    3\n"+
+		"cache  skipped    0     3ms       1\n"+
+		"ci     not-run    -     -         -\n",
+		buffer.String())
+}
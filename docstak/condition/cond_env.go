@@ -0,0 +1,93 @@
+/*
+Copyright 2024 Kasai Kou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package condition
+
+import (
+	"context"
+	"os"
+	"sort"
+
+	"github.com/kasaikou/markflow/docstak/model"
+)
+
+type EnvIsSet struct {
+	Environ map[string]string
+	Key     string
+}
+
+// Returns the reason whether the variable is set, even if it is empty.
+func (cond *EnvIsSet) Explain(ctx context.Context, rule string) Reason {
+	_, exist := cond.Environ[cond.Key]
+	return Reason{
+		Rule:      rule,
+		Kind:      ReasonEnvSet,
+		Satisfied: exist,
+		Variable:  cond.Key,
+	}
+}
+
+func (cond *EnvIsSet) IsEnable(ctx context.Context) (bool, error) {
+	_, exist := cond.Environ[cond.Key]
+	return exist, nil
+}
+
+type EnvEquals struct {
+	Environ map[string]string
+	Key     string
+	Value   string
+}
+
+// Returns the reason whether the variable equals the value.
+// The current value is not included in the reason, since it may be a secret.
+func (cond *EnvEquals) Explain(ctx context.Context, rule string) Reason {
+	value, exist := cond.Environ[cond.Key]
+	return Reason{
+		Rule:      rule,
+		Kind:      ReasonEnvEquals,
+		Satisfied: exist && value == cond.Value,
+		Variable:  cond.Key,
+		Value:     cond.Value,
+	}
+}
+
+func (cond *EnvEquals) IsEnable(ctx context.Context) (bool, error) {
+	value, exist := cond.Environ[cond.Key]
+	return exist && value == cond.Value, nil
+}
+
+// Appends the environment variable conditions to the container in the order of the variable names.
+func (container *testContainer) appendEnvConditions(dt *model.DocumentTask, env model.TaskEnvCondition) {
+	if len(env.Set) == 0 && len(env.Equals) == 0 {
+		return
+	}
+
+	// Merged from the document, the task and the process like the scripts see.
+	environ := dt.Environ(os.Environ())
+	for i := range env.Set {
+		container.envSets = append(container.envSets, EnvIsSet{Environ: environ, Key: env.Set[i]})
+	}
+
+	keys := make([]string, 0, len(env.Equals))
+	for key := range env.Equals {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		container.envEquals = append(container.envEquals, EnvEquals{Environ: environ, Key: key, Value: env.Equals[key]})
+	}
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2024 Kasai Kou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package condition
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kasaikou/markflow/docstak/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequiresEnv(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv("DOCSTAK_TEST_PROFILE", "")
+	t.Setenv("DOCSTAK_TEST_STAGE", "prod")
+
+	task := &model.DocumentTask{
+		Parent: &model.Document{GlobalEnvs: map[string]string{"DOCSTAK_TEST_REGION": "us", "DOCSTAK_TEST_CI": "false"}},
+		Envs:   map[string]string{"DOCSTAK_TEST_CI": "true"},
+		Requires: model.TaskRequireCondition{
+			Env: model.TaskEnvCondition{
+				Set:    []string{"DOCSTAK_TEST_PROFILE", "DOCSTAK_TEST_REGION"},
+				Equals: map[string]string{"DOCSTAK_TEST_CI": "true", "DOCSTAK_TEST_STAGE": "prod"},
+			},
+		},
+	}
+
+	sufficient, reasons := NewRequiresFromDocumentTask(task).Test(ctx, TestOption{})
+	assert.True(t, sufficient)
+	assert.Len(t, reasons, 4)
+
+	// The process environment takes priority over the document and the task.
+	t.Setenv("DOCSTAK_TEST_CI", "false")
+	task.Requires.Env.Set = append(task.Requires.Env.Set, "DOCSTAK_TEST_MISSING")
+
+	sufficient, reasons = NewRequiresFromDocumentTask(task).Test(ctx, TestOption{})
+	assert.False(t, sufficient)
+	assert.Equal(t, "requires.env.set: 'DOCSTAK_TEST_MISSING' is not set; requires.env.equals: 'DOCSTAK_TEST_CI' does not equal 'true'", JoinReasons(reasons, false))
+}
+
+func TestSkipsEnv(t *testing.T) {
+	ctx := context.Background()
+	task := &model.DocumentTask{
+		Skips: model.TaskSkipCondition{
+			Env: model.TaskEnvCondition{Equals: map[string]string{"DOCSTAK_TEST_OFFLINE": "1"}},
+		},
+	}
+
+	t.Setenv("DOCSTAK_TEST_OFFLINE", "0")
+	skip, _ := NewSkipsFromDocumentTask(task).Test(ctx, TestOption{})
+	assert.False(t, skip)
+
+	t.Setenv("DOCSTAK_TEST_OFFLINE", "1")
+	skip, reasons := NewSkipsFromDocumentTask(task).Test(ctx, TestOption{})
+	assert.True(t, skip)
+	assert.Equal(t, "skips.env.equals: 'DOCSTAK_TEST_OFFLINE' equals '1'", JoinReasons(reasons, true))
+}
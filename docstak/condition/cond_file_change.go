@@ -22,18 +22,24 @@ import (
 	"encoding/hex"
 	"io"
 	"os"
+	"path/filepath"
 	"sort"
 
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/kasaikou/markflow/docstak/model"
 	"github.com/kasaikou/markflow/docstak/resolver"
 )
 
 type FileNotChanged struct {
-	Config resolver.FileGlobConfig
-	MD5    string
+	Config    resolver.FileGlobConfig
+	MD5       string // Compared only when the manifest is not recorded.
+	Manifest  model.FileManifest
+	Algorithm model.HashAlgorithm // Algorithm of the hashes in the manifest.
 }
 
+// Returns the digest of the whole files, which is stored in the previous state format.
 func (cond *FileNotChanged) CurrentMD5(ctx context.Context) (string, error) {
-	results, err := resolver.ResolveFileGlob(cond.Config)
+	results, err := resolver.ResolveFileGlobFullpath(cond.Config)
 	if err != nil {
 		return "", err
 	}
@@ -69,16 +75,129 @@ func (cond *FileNotChanged) CurrentMD5(ctx context.Context) (string, error) {
 	return hex.EncodeToString(hash.Sum(nil)), nil
 }
 
+// Returns the digests of the files matched with the patterns with the algorithm.
+// Files whose size, modification time and inode are same as the recorded manifest are not hashed again.
+func (cond *FileNotChanged) CurrentManifest(ctx context.Context, algorithm model.HashAlgorithm) (model.FileManifest, error) {
+	results, err := resolver.ResolveFileGlob(cond.Config)
+	if err != nil && err != doublestar.ErrPatternNotExist {
+		return nil, err
+	}
+
+	cache := map[string]model.FileDigest{}
+	if cond.Algorithm.OrDefault() == algorithm.OrDefault() {
+		for i := range cond.Manifest {
+			cache[cond.Manifest[i].Path] = cond.Manifest[i]
+		}
+	}
+
+	sort.Strings(results)
+	manifest := make(model.FileManifest, 0, len(results))
+
+	for i := range results {
+		digest, err := func(ctx context.Context) (model.FileDigest, error) {
+			if err := ctx.Err(); err != nil {
+				return model.FileDigest{}, err
+			}
+
+			filename := filepath.Join(cond.Config.Rootdir, results[i])
+			info, err := os.Stat(filename)
+			if err != nil {
+				return model.FileDigest{}, err
+			}
+
+			digest := model.FileDigest{
+				Path:    results[i],
+				Size:    info.Size(),
+				ModTime: info.ModTime(),
+				Inode:   inodeOf(info),
+			}
+
+			if cached, exist := cache[digest.Path]; exist && cached.Size == digest.Size && cached.ModTime.Equal(digest.ModTime) && cached.Inode == digest.Inode {
+				digest.Hash = cached.Hash
+				return digest, nil
+			}
+
+			file, err := os.Open(filename)
+			if err != nil {
+				return model.FileDigest{}, err
+			}
+
+			defer file.Close()
+			hash := algorithm.New()
+			if _, err := io.Copy(hash, file); err != nil {
+				return model.FileDigest{}, err
+			}
+
+			digest.Hash = hex.EncodeToString(hash.Sum(nil))
+			return digest, nil
+		}(ctx)
+
+		if err != nil {
+			return nil, err
+		}
+
+		manifest = append(manifest, digest)
+	}
+
+	return manifest, nil
+}
+
+// Returns the reason whether files matched with the patterns are changed from the previous state,
+// with the files added, removed and modified.
+func (cond *FileNotChanged) Explain(ctx context.Context, rule string) Reason {
+	reason := Reason{
+		Rule:     rule,
+		Kind:     ReasonFileNotChanged,
+		Patterns: cond.Config.Rules,
+	}
+
+	if cond.Manifest == nil {
+		// Migrated from the previous state format, which has only the digest of the whole files.
+		reason.OldHash = cond.MD5
+		current, err := cond.CurrentMD5(ctx)
+		if err != nil && err != doublestar.ErrPatternNotExist {
+			reason.Error = err.Error()
+			return reason
+		}
+
+		reason.NewHash = current
+		reason.Satisfied = cond.MD5 != "" && cond.MD5 == current
+		return reason
+	}
+
+	// Compare with the same algorithm as the recorded manifest, even if the document changes it.
+	current, err := cond.CurrentManifest(ctx, cond.Algorithm)
+	if err != nil {
+		reason.Error = err.Error()
+		return reason
+	}
+
+	reason.OldHash = cond.Manifest.Digest()
+	reason.NewHash = current.Digest()
+	reason.Added, reason.Removed, reason.Modified = cond.Manifest.Diff(current)
+	reason.Satisfied = len(reason.Added)+len(reason.Removed)+len(reason.Modified) == 0
+	return reason
+}
+
 func (cond *FileNotChanged) IsEnable(ctx context.Context) (bool, error) {
 
-	if cond.MD5 == "" {
-		return false, nil
+	if cond.Manifest == nil {
+		if cond.MD5 == "" {
+			return false, nil
+		}
+
+		current, err := cond.CurrentMD5(ctx)
+		if err != nil {
+			return false, err
+		}
+
+		return cond.MD5 == current, nil
 	}
 
-	current, err := cond.CurrentMD5(ctx)
+	current, err := cond.CurrentManifest(ctx, cond.Algorithm)
 	if err != nil {
 		return false, err
 	}
 
-	return cond.MD5 == current, nil
+	return cond.Manifest.Digest() == current.Digest(), nil
 }
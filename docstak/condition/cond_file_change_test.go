@@ -0,0 +1,167 @@
+/*
+Copyright 2024 Kasai Kou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package condition
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kasaikou/markflow/docstak/model"
+	"github.com/kasaikou/markflow/docstak/resolver"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCurrentManifestCache(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	modified := time.Now().Add(-time.Hour)
+	writeFileAt(t, filepath.Join(dir, "a.txt"), modified)
+
+	cond := &FileNotChanged{
+		Config: resolver.FileGlobConfig{Rootdir: dir, Rules: []string{"*.txt"}},
+	}
+
+	manifest, err := cond.CurrentManifest(ctx, model.HashMD5)
+	if !assert.NoError(t, err) || !assert.Len(t, manifest, 1) {
+		return
+	}
+	cond.Manifest, cond.Algorithm = manifest, model.HashMD5
+
+	// Rewrite the content keeping the size and the modification time, which is not hashed again.
+	filename := filepath.Join(dir, "a.txt")
+	content, _ := os.ReadFile(filename)
+	content[0]++
+	if err := os.WriteFile(filename, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(filename, modified, modified); err != nil {
+		t.Fatal(err)
+	}
+
+	current, err := cond.CurrentManifest(ctx, model.HashMD5)
+	if assert.NoError(t, err) {
+		assert.Equal(t, manifest[0].Hash, current[0].Hash)
+	}
+
+	// Files are hashed again with another algorithm.
+	current, err = cond.CurrentManifest(ctx, model.HashSHA256)
+	if assert.NoError(t, err) {
+		assert.Len(t, current[0].Hash, 64)
+	}
+
+	// Touched files are hashed again.
+	if err := os.Chtimes(filename, time.Now(), time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	current, err = cond.CurrentManifest(ctx, model.HashMD5)
+	if assert.NoError(t, err) {
+		assert.NotEqual(t, manifest[0].Hash, current[0].Hash)
+	}
+}
+
+func TestSkipsHashAlgorithm(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	writeFileAt(t, filepath.Join(dir, "a.txt"), time.Now())
+
+	task := &model.DocumentTask{
+		Parent:  &model.Document{HashAlgorithm: model.HashSHA256},
+		Workdir: dir,
+		Skips: model.TaskSkipCondition{
+			NotChangedPaths: []model.TaskFileNotChangedCondition{
+				{Paths: map[string]struct{}{"*.txt": {}}},
+			},
+		},
+	}
+
+	ctx = withDiscardLogger(ctx)
+	NewSkipsFromDocumentTask(task).UpdateDocumentTask(ctx, task)
+	assert.Equal(t, model.HashSHA256, task.Skips.NotChangedPaths[0].Algorithm)
+	assert.Len(t, task.Skips.NotChangedPaths[0].Manifest[0].Hash, 64)
+
+	// The recorded manifest is compared with its own algorithm after the document changes it.
+	task.Parent = &model.Document{HashAlgorithm: model.HashMD5}
+	skip, _ := NewSkipsFromDocumentTask(task).Test(ctx, TestOption{})
+	assert.True(t, skip)
+}
+
+// Creates the synthetic tree of files, like a repository with source codes and vendored assets.
+func newBenchmarkTree(b *testing.B, files int, size int) string {
+	dir := b.TempDir()
+	content := make([]byte, size)
+	for i := range content {
+		content[i] = byte(i)
+	}
+
+	for i := 0; i < files; i++ {
+		filename := filepath.Join(dir, fmt.Sprintf("pkg%03d", i/100), fmt.Sprintf("file%05d.go", i))
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			b.Fatal(err)
+		}
+		if err := os.WriteFile(filename, content, 0644); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+func BenchmarkCurrentManifest(b *testing.B) {
+	ctx := context.Background()
+	dir := newBenchmarkTree(b, 2000, 16*1024)
+	config := resolver.FileGlobConfig{Rootdir: dir, Rules: []string{"**/*.go"}}
+
+	for _, algorithm := range []model.HashAlgorithm{model.HashMD5, model.HashSHA256} {
+		b.Run(fmt.Sprintf("%s/full", algorithm), func(b *testing.B) {
+			cond := &FileNotChanged{Config: config}
+			for i := 0; i < b.N; i++ {
+				if _, err := cond.CurrentManifest(ctx, algorithm); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("%s/cached", algorithm), func(b *testing.B) {
+			cond := &FileNotChanged{Config: config, Algorithm: algorithm}
+			manifest, err := cond.CurrentManifest(ctx, algorithm)
+			if err != nil {
+				b.Fatal(err)
+			}
+			cond.Manifest = manifest
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := cond.CurrentManifest(ctx, algorithm); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+
+	b.Run("legacy-md5", func(b *testing.B) {
+		cond := &FileNotChanged{Config: config}
+		for i := 0; i < b.N; i++ {
+			if _, err := cond.CurrentMD5(ctx); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
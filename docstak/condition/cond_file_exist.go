@@ -19,6 +19,7 @@ package condition
 import (
 	"context"
 
+	"github.com/bmatcuk/doublestar/v4"
 	"github.com/kasaikou/markflow/docstak/resolver"
 )
 
@@ -26,6 +27,25 @@ type FileIsExisted struct {
 	Config resolver.FileGlobConfig
 }
 
+// Returns the reason whether files matched with the patterns exist.
+func (cond *FileIsExisted) Explain(ctx context.Context, rule string) Reason {
+	reason := Reason{
+		Rule:     rule,
+		Kind:     ReasonFileExist,
+		Patterns: cond.Config.Rules,
+	}
+
+	results, err := resolver.ResolveFileGlob(cond.Config)
+	if err != nil && err != doublestar.ErrPatternNotExist {
+		reason.Error = err.Error()
+		return reason
+	}
+
+	reason.Matched = results
+	reason.Satisfied = len(results) > 0
+	return reason
+}
+
 func (cond *FileIsExisted) IsEnable(ctx context.Context) (bool, error) {
 	results, err := resolver.ResolveFileGlob(cond.Config)
 	if err != nil {
@@ -0,0 +1,100 @@
+/*
+Copyright 2024 Kasai Kou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package condition
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/kasaikou/markflow/docstak/resolver"
+)
+
+// Satisfied when no input file is modified after the oldest output file, like make.
+type FileIsNewer struct {
+	Config  resolver.FileGlobConfig // Input files.
+	Outputs resolver.FileGlobConfig
+}
+
+// Returns the matched file which is modified at the latest, or the earliest when oldest is true.
+func modifiedFileOf(ctx context.Context, config resolver.FileGlobConfig, oldest bool) (string, time.Time, error) {
+	results, err := resolver.ResolveFileGlob(config)
+	if err != nil && err != doublestar.ErrPatternNotExist {
+		return "", time.Time{}, err
+	}
+
+	filename, modified := "", time.Time{}
+	for i := range results {
+		if err := ctx.Err(); err != nil {
+			return "", time.Time{}, err
+		}
+
+		info, err := os.Stat(filepath.Join(config.Rootdir, results[i]))
+		if err != nil {
+			return "", time.Time{}, err
+		}
+
+		if filename == "" || (oldest && info.ModTime().Before(modified)) || (!oldest && info.ModTime().After(modified)) {
+			filename, modified = results[i], info.ModTime()
+		}
+	}
+
+	return filename, modified, nil
+}
+
+// Returns the reason whether the output files are newer than the input files.
+func (cond *FileIsNewer) Explain(ctx context.Context, rule string) Reason {
+	reason := Reason{
+		Rule:     rule,
+		Kind:     ReasonFileNewerThan,
+		Patterns: cond.Config.Rules,
+		Outputs:  cond.Outputs.Rules,
+	}
+
+	output, outputModified, err := modifiedFileOf(ctx, cond.Outputs, true)
+	if err != nil {
+		reason.Error = err.Error()
+		return reason
+	}
+
+	input, inputModified, err := modifiedFileOf(ctx, cond.Config, false)
+	if err != nil {
+		reason.Error = err.Error()
+		return reason
+	}
+
+	reason.NewestInput = input
+	reason.OldestOutput = output
+	reason.Satisfied = output != "" && !inputModified.After(outputModified)
+	return reason
+}
+
+func (cond *FileIsNewer) IsEnable(ctx context.Context) (bool, error) {
+	output, outputModified, err := modifiedFileOf(ctx, cond.Outputs, true)
+	if err != nil || output == "" {
+		return false, err
+	}
+
+	_, inputModified, err := modifiedFileOf(ctx, cond.Config, false)
+	if err != nil {
+		return false, err
+	}
+
+	return !inputModified.After(outputModified), nil
+}
@@ -0,0 +1,136 @@
+/*
+Copyright 2024 Kasai Kou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package condition
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/kasaikou/markflow/docstak/model"
+)
+
+// Satisfied when the task definition is same as the one when the task ran last time.
+type TaskDefinitionNotChanged struct {
+	Task        *model.DocumentTask
+	Params      map[string]string
+	ExecVersion bool
+	Definition  string // Fingerprint recorded when the task ran last time.
+}
+
+type fingerprintExec struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+}
+
+type fingerprintScript struct {
+	Config model.ExecConfig `json:"config"`
+	Script string           `json:"script"`
+	Exec   *fingerprintExec `json:"exec,omitempty"`
+}
+
+// Returns the hash of the scripts, their interpreters, the working directory, the environment variables and the parameters.
+// The environment variables are the ones of the document merged with the ones of the task, as the scripts receive.
+// The interpreter binaries are identified with their size and modification time when ExecVersion is set.
+func (cond *TaskDefinitionNotChanged) Fingerprint(ctx context.Context) (string, error) {
+	definition := struct {
+		Scripts []fingerprintScript `json:"scripts"`
+		Workdir string              `json:"workdir"`
+		Envs    map[string]string   `json:"envs"`
+		Params  map[string]string   `json:"params"`
+	}{
+		Scripts: make([]fingerprintScript, 0, len(cond.Task.Scripts)),
+		Envs:    cond.Task.Environ(nil),
+		Params:  cond.Params,
+	}
+
+	// Moving the whole document does not change the definition.
+	definition.Workdir = cond.Task.WorkingDir()
+	if cond.Task.Parent != nil {
+		if rel, err := filepath.Rel(cond.Task.Parent.Rootdir, definition.Workdir); err == nil {
+			definition.Workdir = filepath.ToSlash(rel)
+		}
+	}
+
+	for _, script := range cond.Task.Scripts {
+		item := fingerprintScript{
+			Config: script.Config,
+			Script: script.Script,
+		}
+
+		if cond.ExecVersion {
+			if err := ctx.Err(); err != nil {
+				return "", err
+			}
+
+			filename, err := filepath.EvalSymlinks(script.Config.ExecPath)
+			if err != nil {
+				return "", err
+			}
+
+			info, err := os.Stat(filename)
+			if err != nil {
+				return "", err
+			}
+
+			item.Exec = &fingerprintExec{Path: filename, Size: info.Size(), ModTime: info.ModTime()}
+		}
+
+		definition.Scripts = append(definition.Scripts, item)
+	}
+
+	hash := md5.New()
+	if err := json.NewEncoder(hash).Encode(definition); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// Returns the reason whether the task definition is changed.
+// The task definition is regarded as not changed when no fingerprint is recorded, like the state in the previous format.
+func (cond *TaskDefinitionNotChanged) Explain(ctx context.Context, rule string) Reason {
+	reason := Reason{
+		Rule:    rule,
+		Kind:    ReasonDefinition,
+		OldHash: cond.Definition,
+	}
+
+	current, err := cond.Fingerprint(ctx)
+	if err != nil {
+		reason.Error = err.Error()
+		return reason
+	}
+
+	reason.NewHash = current
+	reason.Satisfied = cond.Definition == "" || cond.Definition == current
+	return reason
+}
+
+func (cond *TaskDefinitionNotChanged) IsEnable(ctx context.Context) (bool, error) {
+	current, err := cond.Fingerprint(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	return cond.Definition == "" || cond.Definition == current, nil
+}
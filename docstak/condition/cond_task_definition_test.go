@@ -0,0 +1,99 @@
+/*
+Copyright 2024 Kasai Kou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package condition
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kasaikou/markflow/docstak/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSkipsTaskDefinition(t *testing.T) {
+	ctx := withDiscardLogger(context.Background())
+	dir := t.TempDir()
+	writeFileAt(t, filepath.Join(dir, "main.go"), time.Now())
+
+	task := &model.DocumentTask{
+		Parent:  &model.Document{Rootdir: dir, GlobalEnvs: map[string]string{"GOOS": "linux"}},
+		Workdir: dir,
+		Scripts: []model.DocumentTaskScript{
+			{Config: model.ExecConfig{ExecPath: "/bin/sh", CmdOpt: "-c"}, Script: "go build\n"},
+		},
+		Envs: map[string]string{"CGO_ENABLED": "0"},
+		Skips: model.TaskSkipCondition{
+			NotChangedPaths: []model.TaskFileNotChangedCondition{
+				{Paths: map[string]struct{}{"*.go": {}}},
+			},
+			ExecVersion: true,
+		},
+	}
+
+	params := map[string]string{"target": "linux"}
+	NewSkipsFromDocumentTask(task, SkipsOptParams(params)).UpdateDocumentTask(ctx, task)
+	if !assert.NotEmpty(t, task.Skips.Definition) {
+		return
+	}
+
+	skip, _ := NewSkipsFromDocumentTask(task, SkipsOptParams(params)).Test(ctx, TestOption{})
+	assert.True(t, skip)
+
+	for name, edit := range map[string]func(task *model.DocumentTask) (map[string]string, func()){
+		"script": func(task *model.DocumentTask) (map[string]string, func()) {
+			task.Scripts[0].Script = "go build -trimpath\n"
+			return params, func() { task.Scripts[0].Script = "go build\n" }
+		},
+		"interpreter": func(task *model.DocumentTask) (map[string]string, func()) {
+			task.Scripts[0].Config.CmdOpt = "-ec"
+			return params, func() { task.Scripts[0].Config.CmdOpt = "-c" }
+		},
+		"envs": func(task *model.DocumentTask) (map[string]string, func()) {
+			task.Envs["CGO_ENABLED"] = "1"
+			return params, func() { task.Envs["CGO_ENABLED"] = "0" }
+		},
+		"global envs": func(task *model.DocumentTask) (map[string]string, func()) {
+			task.Parent.GlobalEnvs["GOOS"] = "darwin"
+			return params, func() { task.Parent.GlobalEnvs["GOOS"] = "linux" }
+		},
+		"params": func(task *model.DocumentTask) (map[string]string, func()) {
+			return map[string]string{"target": "darwin"}, func() {}
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			params, restore := edit(task)
+			defer restore()
+
+			skip, reasons := NewSkipsFromDocumentTask(task, SkipsOptParams(params)).Test(ctx, TestOption{})
+			assert.False(t, skip)
+			assert.True(t, strings.HasPrefix(JoinReasons(reasons, false), "skips.definition: task definition changed (hash: "+task.Skips.Definition+" -> "))
+		})
+	}
+
+	// Moving the whole document keeps the definition.
+	moved := filepath.Join(t.TempDir(), "moved")
+	movedTask := *task
+	movedTask.Parent = &model.Document{Rootdir: moved, GlobalEnvs: task.Parent.GlobalEnvs}
+	movedTask.Workdir = moved
+	current, err := (&TaskDefinitionNotChanged{Task: &movedTask, Params: params, ExecVersion: true}).Fingerprint(ctx)
+	if assert.NoError(t, err) {
+		assert.Equal(t, task.Skips.Definition, current)
+	}
+}
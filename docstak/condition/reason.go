@@ -0,0 +1,152 @@
+/*
+Copyright 2024 Kasai Kou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package condition
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Kind of the condition which built the reason.
+type ReasonKind string
+
+const (
+	ReasonFileExist      ReasonKind = "file-exist"       // Files matched with the patterns exist.
+	ReasonFileNotChanged ReasonKind = "file-not-changed" // Files matched with the patterns are not changed.
+	ReasonFileNewerThan  ReasonKind = "file-newer-than"  // Outputs are newer than the files matched with the patterns.
+	ReasonDefinition     ReasonKind = "definition"       // Task definition is not changed.
+	ReasonEnvSet         ReasonKind = "env-set"          // Environment variable is set.
+	ReasonEnvEquals      ReasonKind = "env-equals"       // Environment variable equals the value.
+)
+
+// Result of a rule of the conditions, which tells why the task is skipped or runs.
+type Reason struct {
+	Rule      string     `json:"rule"` // Like 'skips.file.not-changed'.
+	Kind      ReasonKind `json:"kind"`
+	Satisfied bool       `json:"satisfied"`
+	Patterns  []string   `json:"patterns,omitempty"`
+	Matched   []string   `json:"matched,omitempty"` // Files matched with the patterns.
+	OldHash   string     `json:"old_hash,omitempty"`
+	NewHash   string     `json:"new_hash,omitempty"`
+	// Files changed from the previous state.
+	Added    []string `json:"added,omitempty"`
+	Removed  []string `json:"removed,omitempty"`
+	Modified []string `json:"modified,omitempty"`
+	// Environment variable and its expected value.
+	Variable string `json:"variable,omitempty"`
+	Value    string `json:"value,omitempty"`
+	// Output patterns, and the files compared by their modification time.
+	Outputs      []string `json:"outputs,omitempty"`
+	NewestInput  string   `json:"newest_input,omitempty"`
+	OldestOutput string   `json:"oldest_output,omitempty"`
+	Error        string   `json:"error,omitempty"`
+}
+
+func (r Reason) String() string {
+	patterns := "'" + strings.Join(r.Patterns, "', '") + "'"
+
+	var message string
+	switch r.Kind {
+	case ReasonEnvSet:
+		if r.Satisfied {
+			message = fmt.Sprintf("'%s' is set", r.Variable)
+		} else {
+			message = fmt.Sprintf("'%s' is not set", r.Variable)
+		}
+
+	case ReasonEnvEquals:
+		if r.Satisfied {
+			message = fmt.Sprintf("'%s' equals '%s'", r.Variable, r.Value)
+		} else {
+			message = fmt.Sprintf("'%s' does not equal '%s'", r.Variable, r.Value)
+		}
+
+	case ReasonDefinition:
+		switch {
+		case r.Error != "":
+			message = fmt.Sprintf("cannot check task definition: %s", r.Error)
+		case r.OldHash == "":
+			message = "no previous task definition"
+		case r.Satisfied:
+			message = "task definition not changed"
+		default:
+			message = fmt.Sprintf("task definition changed (hash: %s -> %s)", r.OldHash, r.NewHash)
+		}
+
+	case ReasonFileNotChanged:
+		switch {
+		case r.Error != "":
+			message = fmt.Sprintf("cannot check files matched with %s: %s", patterns, r.Error)
+		case r.OldHash == "":
+			message = fmt.Sprintf("no previous hash of files matched with %s", patterns)
+		case r.Satisfied:
+			message = fmt.Sprintf("files matched with %s are not changed (hash: %s)", patterns, r.NewHash)
+		case len(r.Added)+len(r.Removed)+len(r.Modified) > 0:
+			changes := []string{}
+			for _, change := range []struct {
+				name  string
+				files []string
+			}{{"added", r.Added}, {"removed", r.Removed}, {"modified", r.Modified}} {
+				if len(change.files) > 0 {
+					changes = append(changes, change.name+": "+strings.Join(change.files, ", "))
+				}
+			}
+			message = fmt.Sprintf("files matched with %s are changed (%s)", patterns, strings.Join(changes, "; "))
+		default:
+			message = fmt.Sprintf("files matched with %s are changed (hash: %s -> %s)", patterns, r.OldHash, r.NewHash)
+		}
+
+	case ReasonFileNewerThan:
+		switch {
+		case r.Error != "":
+			message = fmt.Sprintf("cannot check files matched with %s: %s", patterns, r.Error)
+		case r.OldestOutput == "":
+			message = fmt.Sprintf("no output matched with '%s'", strings.Join(r.Outputs, "', '"))
+		case r.Satisfied:
+			message = fmt.Sprintf("outputs matched with '%s' are newer than files matched with %s", strings.Join(r.Outputs, "', '"), patterns)
+		default:
+			message = fmt.Sprintf("'%s' is newer than the output '%s'", r.NewestInput, r.OldestOutput)
+		}
+
+	case ReasonFileExist:
+		switch {
+		case r.Error != "":
+			message = fmt.Sprintf("cannot check files matched with %s: %s", patterns, r.Error)
+		case r.Satisfied:
+			message = fmt.Sprintf("%d files matched with %s exist", len(r.Matched), patterns)
+		default:
+			message = fmt.Sprintf("no file matched with %s", patterns)
+		}
+
+	default:
+		message = fmt.Sprintf("unknown kind of reason '%s'", r.Kind)
+	}
+
+	return r.Rule + ": " + message
+}
+
+// Returns the reasons joined with '; ', which are filtered with satisfied.
+func JoinReasons(reasons []Reason, satisfied bool) string {
+	messages := make([]string, 0, len(reasons))
+	for i := range reasons {
+		if reasons[i].Satisfied == satisfied {
+			messages = append(messages, reasons[i].String())
+		}
+	}
+
+	return strings.Join(messages, "; ")
+}
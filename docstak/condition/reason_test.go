@@ -0,0 +1,31 @@
+/*
+Copyright 2024 Kasai Kou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package condition
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReasonStringByKind(t *testing.T) {
+	// The message depends on the kind, not on the name of the rule.
+	assert.Equal(t, "custom.rule: 'TOKEN' is not set", Reason{Rule: "custom.rule", Kind: ReasonEnvSet, Variable: "TOKEN"}.String())
+	assert.Equal(t, "custom.rule: no previous task definition", Reason{Rule: "custom.rule", Kind: ReasonDefinition}.String())
+	assert.Equal(t, "custom.rule: no file matched with '*.go'", Reason{Rule: "custom.rule", Kind: ReasonFileExist, Patterns: []string{"*.go"}}.String())
+	assert.Equal(t, "skips.file.exist: unknown kind of reason ''", Reason{Rule: "skips.file.exist", Satisfied: true}.String())
+}
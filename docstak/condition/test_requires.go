@@ -18,11 +18,7 @@ package condition
 
 import (
 	"context"
-	"log/slog"
-	"strings"
 
-	"github.com/bmatcuk/doublestar/v4"
-	"github.com/kasaikou/markflow/docstak"
 	"github.com/kasaikou/markflow/docstak/model"
 	"github.com/kasaikou/markflow/docstak/resolver"
 )
@@ -32,8 +28,13 @@ type Requires struct {
 }
 
 type testContainer struct {
+	generateFiles   []FileIsExisted
 	existFiles      []FileIsExisted
 	notChangedFiles []FileNotChanged
+	newerFiles      []FileIsNewer
+	definitions     []TaskDefinitionNotChanged
+	envSets         []EnvIsSet
+	envEquals       []EnvEquals
 }
 
 type TestOption struct{}
@@ -44,61 +45,44 @@ func NewRequiresFromDocumentTask(dt *model.DocumentTask) *Requires {
 	for i := range dt.Requires.ExistPaths {
 		container.existFiles = append(container.existFiles, FileIsExisted{
 			Config: resolver.FileGlobConfig{
-				Rootdir: dt.Parent.Rootdir,
+				Rootdir: dt.WorkingDir(),
 				Rules:   []string{dt.Requires.ExistPaths[i]},
 			},
 		})
 	}
 
+	container.appendEnvConditions(dt, dt.Requires.Env)
+
 	requires.container = append(requires.container, container)
 	return requires
 }
 
-func (r *Requires) Test(ctx context.Context, opts TestOption) (sufficient bool) {
-	logger := docstak.GetLogger(ctx)
-
-	logFns := make([]func(), 0, len(r.container))
-
+// Returns whether the requirements of the task are sufficient, and the reasons of every rule.
+func (r *Requires) Test(ctx context.Context, opts TestOption) (sufficient bool, reasons []Reason) {
 	for itemIdx := range r.container {
 		valid := true
 		for ruleIdx := range r.container[itemIdx].existFiles {
+			reason := r.container[itemIdx].existFiles[ruleIdx].Explain(ctx, "requires.file.exist")
+			valid = valid && reason.Satisfied
+			reasons = append(reasons, reason)
+		}
 
-			enable, err := r.container[itemIdx].existFiles[ruleIdx].IsEnable(ctx)
-
-			if err != nil {
-				if err == doublestar.ErrPatternNotExist {
-					logFns = append(logFns, func() {
-						logger.Error("cannot found files matched with patterns",
-							slog.String("pattern", strings.Join(r.container[itemIdx].existFiles[ruleIdx].Config.Rules, " | ")),
-						)
-					})
-					valid = false
-
-				} else {
-					logger.Warn("returns error when check file is exist (check glob pattern is valid or not)", slog.Any("error", err))
-					logFns = append(logFns, func() {
-						logger.Error("returns error when check file is exist (check glob pattern is valid or not)", slog.Any("error", err))
-					})
-				}
-
-			} else if !enable {
-				logFns = append(logFns, func() {
-					logger.Error("cannot found files matched with patterns",
-						slog.String("pattern", strings.Join(r.container[itemIdx].existFiles[ruleIdx].Config.Rules, " | ")),
-					)
-				})
-				valid = false
-			}
+		for ruleIdx := range r.container[itemIdx].envSets {
+			reason := r.container[itemIdx].envSets[ruleIdx].Explain(ctx, "requires.env.set")
+			valid = valid && reason.Satisfied
+			reasons = append(reasons, reason)
 		}
 
-		if valid {
-			return true
+		for ruleIdx := range r.container[itemIdx].envEquals {
+			reason := r.container[itemIdx].envEquals[ruleIdx].Explain(ctx, "requires.env.equals")
+			valid = valid && reason.Satisfied
+			reasons = append(reasons, reason)
 		}
-	}
 
-	for i := range logFns {
-		logFns[i]()
+		if valid {
+			return true, reasons
+		}
 	}
 
-	return false
+	return false, reasons
 }
@@ -20,7 +20,6 @@ import (
 	"context"
 	"log/slog"
 
-	"github.com/bmatcuk/doublestar/v4"
 	"github.com/kasaikou/markflow/docstak"
 	"github.com/kasaikou/markflow/docstak/model"
 	"github.com/kasaikou/markflow/docstak/resolver"
@@ -28,15 +27,42 @@ import (
 
 type Skips struct {
 	container []testContainer
+	algorithm model.HashAlgorithm // Algorithm to record the manifests.
+	params    map[string]string
 }
 
-func NewSkipsFromDocumentTask(dt *model.DocumentTask) *Skips {
+type SkipsOption func(s *Skips)
+
+// Set the resolved parameter values of the task, which are a part of the task definition.
+func SkipsOptParams(params map[string]string) SkipsOption {
+	return func(s *Skips) {
+		s.params = params
+	}
+}
+
+func NewSkipsFromDocumentTask(dt *model.DocumentTask, opts ...SkipsOption) *Skips {
 	skips := &Skips{}
+	if dt.Parent != nil {
+		skips.algorithm = dt.Parent.HashAlgorithm
+	}
+	for _, opt := range opts {
+		opt(skips)
+	}
+
 	container := testContainer{}
+	for i := range dt.Skips.GeneratePaths {
+		container.generateFiles = append(container.generateFiles, FileIsExisted{
+			Config: resolver.FileGlobConfig{
+				Rootdir: dt.WorkingDir(),
+				Rules:   []string{dt.Skips.GeneratePaths[i]},
+			},
+		})
+	}
+
 	for i := range dt.Skips.ExistPaths {
 		container.existFiles = append(container.existFiles, FileIsExisted{
 			Config: resolver.FileGlobConfig{
-				Rootdir: dt.Parent.Rootdir,
+				Rootdir: dt.WorkingDir(),
 				Rules:   []string{dt.Skips.ExistPaths[i]},
 			},
 		})
@@ -55,11 +81,38 @@ func NewSkipsFromDocumentTask(dt *model.DocumentTask) *Skips {
 
 		container.notChangedFiles = append(container.notChangedFiles, FileNotChanged{
 			Config: resolver.FileGlobConfig{
-				Rootdir:    dt.Parent.Rootdir,
+				Rootdir:    dt.WorkingDir(),
 				Rules:      paths,
 				IgnoreRule: ignores,
 			},
-			MD5: dt.Skips.NotChangedPaths[i].MD5,
+			MD5:       dt.Skips.NotChangedPaths[i].MD5,
+			Manifest:  dt.Skips.NotChangedPaths[i].Manifest,
+			Algorithm: dt.Skips.NotChangedPaths[i].Algorithm,
+		})
+	}
+
+	if len(dt.Skips.NewerThanPaths) > 0 {
+		container.newerFiles = append(container.newerFiles, FileIsNewer{
+			Config: resolver.FileGlobConfig{
+				Rootdir: dt.WorkingDir(),
+				Rules:   dt.Skips.NewerThanPaths,
+			},
+			Outputs: resolver.FileGlobConfig{
+				Rootdir: dt.WorkingDir(),
+				Rules:   dt.Skips.GeneratePaths,
+			},
+		})
+	}
+
+	container.appendEnvConditions(dt, dt.Skips.Env)
+
+	// Changes of the task definition are detected only with the rules which cache the result of the task.
+	if len(dt.Skips.NotChangedPaths) > 0 || len(dt.Skips.NewerThanPaths) > 0 {
+		container.definitions = append(container.definitions, TaskDefinitionNotChanged{
+			Task:        dt,
+			Params:      skips.params,
+			ExecVersion: dt.Skips.ExecVersion,
+			Definition:  dt.Skips.Definition,
 		})
 	}
 
@@ -67,59 +120,74 @@ func NewSkipsFromDocumentTask(dt *model.DocumentTask) *Skips {
 	return skips
 }
 
-func (s *Skips) Test(ctx context.Context, opts TestOption) (skip bool) {
-	logger := docstak.GetLogger(ctx)
-
+// Returns whether the task is skipped, and the reasons of every rule.
+func (s *Skips) Test(ctx context.Context, opts TestOption) (skip bool, reasons []Reason) {
 	for itemIdx := range s.container {
 		skip := true
 		isEmpty := true
 
-		if len(s.container[itemIdx].existFiles) > 0 {
+		// Every output must exist before comparing the inputs with them.
+		for ruleIdx := range s.container[itemIdx].generateFiles {
 			isEmpty = false
-			for ruleIdx := range s.container[itemIdx].existFiles {
+			reason := s.container[itemIdx].generateFiles[ruleIdx].Explain(ctx, "skips.generates")
 
-				enable, err := s.container[itemIdx].existFiles[ruleIdx].IsEnable(ctx)
+			skip = skip && reason.Satisfied
+			reasons = append(reasons, reason)
+		}
 
-				if err != nil {
-					skip = false
-					if err != doublestar.ErrPatternNotExist {
-						logger.Warn("returns error when check file is exist (check glob pattern is valid or not)", slog.Any("error", err))
-					}
+		for ruleIdx := range s.container[itemIdx].existFiles {
+			isEmpty = false
+			reason := s.container[itemIdx].existFiles[ruleIdx].Explain(ctx, "skips.file.exist")
 
-				} else if !enable {
-					skip = false
-				}
-			}
+			skip = skip && reason.Satisfied
+			reasons = append(reasons, reason)
 		}
 
-		if len(s.container[itemIdx].notChangedFiles) > 0 {
+		for ruleIdx := range s.container[itemIdx].notChangedFiles {
 			isEmpty = false
-			for ruleIdx := range s.container[itemIdx].notChangedFiles {
+			reason := s.container[itemIdx].notChangedFiles[ruleIdx].Explain(ctx, "skips.file.not-changed")
 
-				enable, err := s.container[itemIdx].notChangedFiles[ruleIdx].IsEnable(ctx)
+			skip = skip && reason.Satisfied
+			reasons = append(reasons, reason)
+		}
 
-				if err != nil {
-					skip = false
-					if err != doublestar.ErrPatternNotExist {
-						logger.Warn("returns error when check file is exist (check glob pattern is valid or not)", slog.Any("error", err))
-					}
+		for ruleIdx := range s.container[itemIdx].newerFiles {
+			isEmpty = false
+			reason := s.container[itemIdx].newerFiles[ruleIdx].Explain(ctx, "skips.file.newer-than")
 
-				} else if !enable {
-					skip = false
-				}
-			}
+			skip = skip && reason.Satisfied
+			reasons = append(reasons, reason)
+		}
+
+		for ruleIdx := range s.container[itemIdx].envSets {
+			isEmpty = false
+			reason := s.container[itemIdx].envSets[ruleIdx].Explain(ctx, "skips.env.set")
+
+			skip = skip && reason.Satisfied
+			reasons = append(reasons, reason)
+		}
+
+		for ruleIdx := range s.container[itemIdx].envEquals {
+			isEmpty = false
+			reason := s.container[itemIdx].envEquals[ruleIdx].Explain(ctx, "skips.env.equals")
+
+			skip = skip && reason.Satisfied
+			reasons = append(reasons, reason)
 		}
 
-		if isEmpty {
-			skip = false
+		for ruleIdx := range s.container[itemIdx].definitions {
+			reason := s.container[itemIdx].definitions[ruleIdx].Explain(ctx, "skips.definition")
+
+			skip = skip && reason.Satisfied
+			reasons = append(reasons, reason)
 		}
 
-		if skip {
-			return true
+		if !isEmpty && skip {
+			return true, reasons
 		}
 	}
 
-	return false
+	return false, reasons
 }
 
 func (s *Skips) UpdateDocumentTask(ctx context.Context, dt *model.DocumentTask) {
@@ -127,12 +195,23 @@ func (s *Skips) UpdateDocumentTask(ctx context.Context, dt *model.DocumentTask)
 
 	for itemIdx := range s.container {
 		for ruleIdx := range s.container[itemIdx].notChangedFiles {
-			hash, err := s.container[itemIdx].notChangedFiles[ruleIdx].CurrentMD5(ctx)
+			manifest, err := s.container[itemIdx].notChangedFiles[ruleIdx].CurrentManifest(ctx, s.algorithm)
+			if err != nil {
+				logger.Warn("failed to calculate hash of files", slog.Any("error", err))
+			} else {
+				logger.Info("update skip when files not changed rule's hash", slog.String("call", dt.Call), slog.String("hash", manifest.Digest()), slog.Int("files", len(manifest)))
+				dt.Skips.NotChangedPaths[ruleIdx].MD5 = ""
+				dt.Skips.NotChangedPaths[ruleIdx].Manifest = manifest
+				dt.Skips.NotChangedPaths[ruleIdx].Algorithm = s.algorithm.OrDefault()
+			}
+		}
+
+		for ruleIdx := range s.container[itemIdx].definitions {
+			definition, err := s.container[itemIdx].definitions[ruleIdx].Fingerprint(ctx)
 			if err != nil {
-				logger.Warn("failed to calculate md5", slog.Any("error", err))
+				logger.Warn("failed to calculate fingerprint of task definition", slog.Any("error", err))
 			} else {
-				logger.Info("update skip when files not changed rule's hash", slog.String("call", dt.Call), slog.String("hash", hash))
-				dt.Skips.NotChangedPaths[ruleIdx].MD5 = hash
+				dt.Skips.Definition = definition
 			}
 		}
 	}
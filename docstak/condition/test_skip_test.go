@@ -0,0 +1,202 @@
+/*
+Copyright 2024 Kasai Kou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package condition
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kasaikou/markflow/docstak"
+	"github.com/kasaikou/markflow/docstak/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeFileAt(t *testing.T, filename string, modified time.Time) {
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filename, []byte(filename), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(filename, modified, modified); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func withDiscardLogger(ctx context.Context) context.Context {
+	return docstak.WithLogger(ctx, slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func TestSkipsNewerThan(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	now := time.Now()
+
+	task := &model.DocumentTask{
+		Workdir: dir,
+		Skips: model.TaskSkipCondition{
+			NewerThanPaths: []string{"src/**.go"},
+			GeneratePaths:  []string{"bin/app", "bin/*.txt"},
+		},
+	}
+
+	writeFileAt(t, filepath.Join(dir, "src", "main.go"), now.Add(-time.Hour))
+	writeFileAt(t, filepath.Join(dir, "bin", "app"), now)
+
+	skip, reasons := NewSkipsFromDocumentTask(task).Test(ctx, TestOption{})
+	assert.False(t, skip)
+	if assert.Len(t, reasons, 4) {
+		assert.Equal(t, "skips.generates: no file matched with 'bin/*.txt'", reasons[1].String())
+	}
+
+	writeFileAt(t, filepath.Join(dir, "bin", "version.txt"), now.Add(-time.Minute))
+	skip, reasons = NewSkipsFromDocumentTask(task).Test(ctx, TestOption{})
+	assert.True(t, skip)
+	assert.Equal(t, Reason{
+		Rule:         "skips.file.newer-than",
+		Kind:         ReasonFileNewerThan,
+		Satisfied:    true,
+		Patterns:     []string{"src/**.go"},
+		Outputs:      []string{"bin/app", "bin/*.txt"},
+		NewestInput:  "src/main.go",
+		OldestOutput: "bin/version.txt",
+	}, reasons[2])
+
+	writeFileAt(t, filepath.Join(dir, "src", "util.go"), now.Add(-time.Second))
+	skip, reasons = NewSkipsFromDocumentTask(task).Test(ctx, TestOption{})
+	assert.False(t, skip)
+	assert.Equal(t, "skips.file.newer-than: 'src/util.go' is newer than the output 'bin/version.txt'", reasons[2].String())
+
+	// Deleting an output always makes the task run.
+	if err := os.Remove(filepath.Join(dir, "bin", "app")); err != nil {
+		t.Fatal(err)
+	}
+	writeFileAt(t, filepath.Join(dir, "bin", "version.txt"), now)
+	skip, _ = NewSkipsFromDocumentTask(task).Test(ctx, TestOption{})
+	assert.False(t, skip)
+}
+
+func TestSkipsGeneratesWithNotChanged(t *testing.T) {
+	ctx := withDiscardLogger(context.Background())
+	dir := t.TempDir()
+	writeFileAt(t, filepath.Join(dir, "src", "main.go"), time.Now())
+
+	task := &model.DocumentTask{
+		Workdir: dir,
+		Skips: model.TaskSkipCondition{
+			NotChangedPaths: []model.TaskFileNotChangedCondition{
+				{Paths: map[string]struct{}{"src/**.go": {}}},
+			},
+			GeneratePaths: []string{"bin/app"},
+		},
+	}
+
+	NewSkipsFromDocumentTask(task).UpdateDocumentTask(ctx, task)
+
+	// The hash of the inputs is not changed, but the output is deleted.
+	skip, reasons := NewSkipsFromDocumentTask(task).Test(ctx, TestOption{})
+	assert.False(t, skip)
+	assert.Equal(t, "skips.generates: no file matched with 'bin/app'", JoinReasons(reasons, false))
+
+	writeFileAt(t, filepath.Join(dir, "bin", "app"), time.Now())
+	skip, _ = NewSkipsFromDocumentTask(task).Test(ctx, TestOption{})
+	assert.True(t, skip)
+}
+
+func TestSkipsNotChangedManifest(t *testing.T) {
+	ctx := withDiscardLogger(context.Background())
+	dir := t.TempDir()
+	for _, name := range []string{"a.go", "b.go", "c.go"} {
+		writeFileAt(t, filepath.Join(dir, name), time.Now())
+	}
+
+	task := &model.DocumentTask{
+		Workdir: dir,
+		Skips: model.TaskSkipCondition{
+			NotChangedPaths: []model.TaskFileNotChangedCondition{
+				{Paths: map[string]struct{}{"*.go": {}}},
+			},
+		},
+	}
+
+	skip, reasons := NewSkipsFromDocumentTask(task).Test(ctx, TestOption{})
+	assert.False(t, skip)
+	assert.Equal(t, "skips.file.not-changed: no previous hash of files matched with '*.go'", JoinReasons(reasons, false))
+
+	NewSkipsFromDocumentTask(task).UpdateDocumentTask(ctx, task)
+	if assert.Len(t, task.Skips.NotChangedPaths[0].Manifest, 3) {
+		assert.Equal(t, "a.go", task.Skips.NotChangedPaths[0].Manifest[0].Path)
+		assert.Equal(t, int64(len(filepath.Join(dir, "a.go"))), task.Skips.NotChangedPaths[0].Manifest[0].Size)
+	}
+
+	skip, _ = NewSkipsFromDocumentTask(task).Test(ctx, TestOption{})
+	assert.True(t, skip)
+
+	// Rename b.go with the same content, and modify c.go.
+	if err := os.Rename(filepath.Join(dir, "b.go"), filepath.Join(dir, "d.go")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "c.go"), []byte("package c"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	skip, reasons = NewSkipsFromDocumentTask(task).Test(ctx, TestOption{})
+	assert.False(t, skip)
+	if assert.Len(t, reasons, 2) {
+		assert.Equal(t, []string{"d.go"}, reasons[0].Added)
+		assert.Equal(t, []string{"b.go"}, reasons[0].Removed)
+		assert.Equal(t, []string{"c.go"}, reasons[0].Modified)
+		assert.Equal(t, "skips.file.not-changed: files matched with '*.go' are changed (added: d.go; removed: b.go; modified: c.go)", reasons[0].String())
+	}
+}
+
+func TestSkipsNotChangedLegacyMD5(t *testing.T) {
+	ctx := withDiscardLogger(context.Background())
+	dir := t.TempDir()
+	writeFileAt(t, filepath.Join(dir, "a.go"), time.Now())
+
+	task := &model.DocumentTask{
+		Workdir: dir,
+		Skips: model.TaskSkipCondition{
+			NotChangedPaths: []model.TaskFileNotChangedCondition{
+				{Paths: map[string]struct{}{"*.go": {}}},
+			},
+		},
+	}
+
+	// The state without version has only the digest of the whole files.
+	hash, err := NewSkipsFromDocumentTask(task).container[0].notChangedFiles[0].CurrentMD5(ctx)
+	if !assert.NoError(t, err) {
+		return
+	}
+	task.Skips.NotChangedPaths[0].MD5 = hash
+
+	skip, _ := NewSkipsFromDocumentTask(task).Test(ctx, TestOption{})
+	assert.True(t, skip)
+
+	NewSkipsFromDocumentTask(task).UpdateDocumentTask(ctx, task)
+	assert.Empty(t, task.Skips.NotChangedPaths[0].MD5)
+	assert.Len(t, task.Skips.NotChangedPaths[0].Manifest, 1)
+
+	skip, _ = NewSkipsFromDocumentTask(task).Test(ctx, TestOption{})
+	assert.True(t, skip)
+}
@@ -32,3 +32,54 @@ func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
 func GetLogger(ctx context.Context) *slog.Logger {
 	return ctx.Value(ctxLoggerKey).(*slog.Logger)
 }
+
+// Get the logger, or false when the context has no logger.
+func LookupLogger(ctx context.Context) (*slog.Logger, bool) {
+	logger, exist := ctx.Value(ctxLoggerKey).(*slog.Logger)
+	return logger, exist
+}
+
+type ctxScriptIndex struct{}
+
+var ctxScriptIndexKey = ctxScriptIndex{}
+
+// Set the index of the script in the task which is executed.
+func WithScriptIndex(ctx context.Context, idx int) context.Context {
+	return context.WithValue(ctx, ctxScriptIndexKey, idx)
+}
+
+// Get the index of the script in the task which is executed.
+func GetScriptIndex(ctx context.Context) (idx int, exist bool) {
+	idx, exist = ctx.Value(ctxScriptIndexKey).(int)
+	return idx, exist
+}
+
+type ctxAttempt struct{}
+
+var ctxAttemptKey = ctxAttempt{}
+
+// Set the attempt number (starting from 1) of the script which is executed.
+func WithAttempt(ctx context.Context, attempt int) context.Context {
+	return context.WithValue(ctx, ctxAttemptKey, attempt)
+}
+
+// Get the attempt number (starting from 1) of the script which is executed.
+func GetAttempt(ctx context.Context) (attempt int, exist bool) {
+	attempt, exist = ctx.Value(ctxAttemptKey).(int)
+	return attempt, exist
+}
+
+type ctxParams struct{}
+
+var ctxParamsKey = ctxParams{}
+
+// Set the resolved parameter values of the task which is executed.
+func WithParams(ctx context.Context, params map[string]string) context.Context {
+	return context.WithValue(ctx, ctxParamsKey, params)
+}
+
+// Get the resolved parameter values of the task which is executed.
+func GetParams(ctx context.Context) (params map[string]string, exist bool) {
+	params, exist = ctx.Value(ctxParamsKey).(map[string]string)
+	return params, exist
+}
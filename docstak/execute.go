@@ -21,17 +21,36 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
+	"os/exec"
 	"runtime"
+	"slices"
+	"strings"
 	"sync"
+	"time"
 
+	"github.com/cockroachdb/errors"
 	"github.com/kasaikou/markflow/docstak/model"
 	"github.com/kasaikou/markflow/docstak/srun"
 )
 
+// Exit code of the task which timed out, same as timeout(1).
+const ExitCodeTimeout = 124
+
+// Cause of the context canceled when a code block timed out.
+var ErrTimeout = errors.New("timed out")
+
 type executeOptions struct {
 	called    []string
 	onExec    func(ctx context.Context, task model.DocumentTask, runner *srun.ScriptRunner) (int, error)
+	taskCheck func(ctx context.Context, task model.DocumentTask) error
+	succeeded func(ctx context.Context, task model.DocumentTask)
 	numWorker int
+	keepGoing bool
+	params    map[string]map[string]string
+	args      []string
+	timeout   time.Duration
+	// Resolved values of task parameters, set by ExecuteContext().
+	paramValues map[string]map[string]string
 }
 
 func newExecuteOptions() *executeOptions {
@@ -54,6 +73,8 @@ func ExecuteOptCalls(keys ...string) ExecuteOption {
 }
 
 // An optional argument for setting pre- and post-processing when executing tasks using Execute() function.
+// fn is called for every attempt of every code block, and the code block is skipped when fn returns SkipError().
+// Use ExecuteOptTaskCheck() to skip the whole task, which is checked only once before the first code block.
 func ExecuteOptProcessExec(fn func(ctx context.Context, task model.DocumentTask, runner *srun.ScriptRunner) (int, error)) ExecuteOption {
 	return func(eo *executeOptions) error {
 		eo.onExec = fn
@@ -61,8 +82,81 @@ func ExecuteOptProcessExec(fn func(ctx context.Context, task model.DocumentTask,
 	}
 }
 
+// An optional argument for checking whether the task runs, once before its first code block, in Execute() function.
+// The task is skipped when fn returns SkipError(), and fails without running any code block when fn returns the other error.
+func ExecuteOptTaskCheck(fn func(ctx context.Context, task model.DocumentTask) error) ExecuteOption {
+	return func(eo *executeOptions) error {
+		eo.taskCheck = fn
+		return nil
+	}
+}
+
+// An optional argument for post-processing after every code block of the task succeeded in Execute() function.
+// fn is called from the goroutines of the tasks at the same time.
+func ExecuteOptTaskSucceeded(fn func(ctx context.Context, task model.DocumentTask)) ExecuteOption {
+	return func(eo *executeOptions) error {
+		eo.succeeded = fn
+		return nil
+	}
+}
+
+// An optional argument for setting the maximum number of scripts that run at the same time in Execute() function.
+func ExecuteOptWorkers(n int) ExecuteOption {
+	return func(eo *executeOptions) error {
+		if n < 1 {
+			return fmt.Errorf("number of workers must be positive (have: %d)", n)
+		}
+		eo.numWorker = n
+		return nil
+	}
+}
+
+// An optional argument for continuing tasks that do not depend on a failed task in Execute() function.
+func ExecuteOptKeepGoing(keepGoing bool) ExecuteOption {
+	return func(eo *executeOptions) error {
+		eo.keepGoing = keepGoing
+		return nil
+	}
+}
+
+// An optional argument for setting parameters of the called task in Execute() function.
+// The dependencies which are not called use the default values, so their required parameters need them to be called too.
+func ExecuteOptParams(call string, params map[string]string) ExecuteOption {
+	return func(eo *executeOptions) error {
+		if eo.params == nil {
+			eo.params = map[string]map[string]string{}
+		}
+		if eo.params[call] == nil {
+			eo.params[call] = map[string]string{}
+		}
+		for key, value := range params {
+			eo.params[call][key] = value
+		}
+		return nil
+	}
+}
+
+// An optional argument for setting positional arguments passed to the called tasks in Execute() function.
+func ExecuteOptArgs(args ...string) ExecuteOption {
+	return func(eo *executeOptions) error {
+		eo.args = append(eo.args, args...)
+		return nil
+	}
+}
+
+// An optional argument for setting the time limit of code blocks which have no timeout in Execute() function.
+func ExecuteOptTimeout(timeout time.Duration) ExecuteOption {
+	return func(eo *executeOptions) error {
+		if timeout < 0 {
+			return fmt.Errorf("timeout must not be negative (have: %s)", timeout)
+		}
+		eo.timeout = timeout
+		return nil
+	}
+}
+
 // Plan and execute the task.
-func ExecuteContext(ctx context.Context, document model.Document, options ...ExecuteOption) int {
+func ExecuteContext(ctx context.Context, document model.Document, options ...ExecuteOption) ExecuteResult {
 
 	logger := GetLogger(ctx)
 	option := newExecuteOptions()
@@ -70,6 +164,7 @@ func ExecuteContext(ctx context.Context, document model.Document, options ...Exe
 	for i := range options {
 		if err := options[i](option); err != nil {
 			logger.Error("failed to load execute options", slog.String("error", err.Error()))
+			return ExecuteResult{ExitCode: -1}
 		}
 	}
 
@@ -78,8 +173,14 @@ func ExecuteContext(ctx context.Context, document model.Document, options ...Exe
 	for i := 0; i < len(called); i++ {
 		task, exist := document.Tasks[called[i]]
 
+		// Stop before anything executes when the task is not defined.
 		if !exist {
-			logger.Error(fmt.Sprintf("cannot found task '%s'", called[i]))
+			msg := fmt.Sprintf("cannot found task '%s'", called[i])
+			if suggestion := model.SuggestClosest(called[i], document.SortedCalls()); suggestion != "" {
+				msg += fmt.Sprintf(" (did you mean '%s'?)", suggestion)
+			}
+			logger.Error(msg)
+			return ExecuteResult{ExitCode: -1}
 		}
 
 		if _, exist := execTasks[called[i]]; exist {
@@ -90,6 +191,30 @@ func ExecuteContext(ctx context.Context, document model.Document, options ...Exe
 		called = append(called, task.DependTasks...)
 	}
 
+	// Parameters can be set only to the called tasks.
+	for call := range option.params {
+		if _, exist := execTasks[call]; !exist || !slices.Contains(option.called, call) {
+			logger.Error(fmt.Sprintf("parameters are set to task '%s' which is not called", call))
+			return ExecuteResult{ExitCode: -1}
+		}
+	}
+
+	// Validate parameters before anything executes.
+	option.paramValues = make(map[string]map[string]string, len(execTasks))
+	for call := range execTasks {
+		task := document.Tasks[call]
+		values, err := task.ResolveParams(option.params[call])
+		if err != nil {
+			attrs := []any{slog.String("error", err.Error())}
+			if !slices.Contains(option.called, call) {
+				attrs = append(attrs, slog.String("hint", fmt.Sprintf("call dependency '%s' together to set its parameters", call)))
+			}
+			logger.Error("invalid task parameters", attrs...)
+			return ExecuteResult{ExitCode: -1}
+		}
+		option.paramValues[call] = values
+	}
+
 	tasks := make([]string, 0, len(execTasks))
 	for task := range execTasks {
 		tasks = append(tasks, task)
@@ -99,12 +224,23 @@ func ExecuteContext(ctx context.Context, document model.Document, options ...Exe
 }
 
 // Plan and execute the task.
-func executeTasks(ctx context.Context, document model.Document, option *executeOptions, executeTasks []string) int {
+func executeTasks(ctx context.Context, document model.Document, option *executeOptions, executeTasks []string) ExecuteResult {
+	logger := GetLogger(ctx)
 	wg := sync.WaitGroup{}
 
 	type taskResp struct {
-		Call string
-		Exit int
+		Call    string
+		Exit    int
+		Skipped bool // Not executed because the dependent task failed.
+	}
+
+	// Results of the tasks, which are not run unless they are recorded.
+	results := make(map[string]TaskResult, len(executeTasks))
+	resultsMu := sync.Mutex{}
+	record := func(result TaskResult) {
+		resultsMu.Lock()
+		defer resultsMu.Unlock()
+		results[result.Call] = result
 	}
 
 	chTaskResp := make(chan taskResp)
@@ -114,6 +250,15 @@ func executeTasks(ctx context.Context, document model.Document, option *executeO
 	defer cancel()
 	taskChs := make([]chan taskResp, 0, len(executeTasks))
 
+	onSucceeded := func(ctx context.Context, task model.DocumentTask) {
+		if option.succeeded != nil {
+			option.succeeded(WithParams(ctx, option.paramValues[task.Call]), task)
+		}
+	}
+
+	// Limit the number of scripts running at the same time.
+	workers := make(chan struct{}, option.numWorker)
+
 	// Create a Goroutine for each task.
 	for i := range executeTasks {
 		ch := make(chan taskResp, len(executeTasks))
@@ -123,6 +268,14 @@ func executeTasks(ctx context.Context, document model.Document, option *executeO
 		go func(ctx context.Context, task model.DocumentTask, chEnded <-chan taskResp, chRes chan<- taskResp) {
 			defer wg.Done()
 
+			// Send the task result unless the execution is canceled.
+			send := func(res taskResp) {
+				select {
+				case <-ctx.Done():
+				case chRes <- res:
+				}
+			}
+
 			depends := map[string]struct{}{}
 			for i := range task.DependTasks {
 				depends[task.DependTasks[i]] = struct{}{}
@@ -136,35 +289,118 @@ func executeTasks(ctx context.Context, document model.Document, option *executeO
 				case <-ctx.Done():
 					return
 				case res := <-chEnded:
+					if _, exist := depends[res.Call]; !exist {
+						continue
+					}
+
+					// Skip the task when the dependent task failed.
+					if res.Exit != 0 {
+						send(taskResp{
+							Call:    task.Call,
+							Exit:    res.Exit,
+							Skipped: true,
+						})
+						return
+					}
 					delete(depends, res.Call)
 				}
 			}
 
+			recorder := newTaskRecorder(task.Call)
+
 			// Terminates when there no scripts set for the task.
 			if len(task.Scripts) == 0 {
-				chRes <- taskResp{
+				record(recorder.finish(false))
+				send(taskResp{
 					Call: task.Call,
 					Exit: 0,
+				})
+				return
+			}
+
+			// The decision applies to every code block of the task.
+			if option.taskCheck != nil {
+				err := option.taskCheck(WithParams(ctx, option.paramValues[task.Call]), task)
+				if errors.Is(err, ErrSkipped) {
+					record(recorder.skip(err.Error()))
+					send(taskResp{
+						Call: task.Call,
+						Exit: 0,
+					})
+					return
+				} else if err != nil {
+					logger.Error("task cannot run", slog.String("task", task.Call), slog.Any("error", err))
+					recorder.add(scriptResult{Exit: -1})
+					record(recorder.finish(false))
+					send(taskResp{
+						Call: task.Call,
+						Exit: -1,
+					})
+					return
+				}
+			}
+
+			// Execute scripts set in a task in order, and stop at the first failure.
+			if !task.Parallel {
+				for j := range task.Scripts {
+					// Wait for a free worker.
+					select {
+					case <-ctx.Done():
+						if j > 0 {
+							record(recorder.finish(true))
+						}
+						return
+					case workers <- struct{}{}:
+					}
+					res := executeTask(WithScriptIndex(ctx, j), task, task.Scripts[j], option)
+					<-workers
+					recorder.add(res)
+
+					if ctx.Err() != nil {
+						record(recorder.finish(true))
+						return
+					}
+
+					if res.Exit != 0 || j == len(task.Scripts)-1 {
+						if res.Exit == 0 {
+							onSucceeded(ctx, task)
+						}
+						record(recorder.finish(false))
+						send(taskResp{
+							Call: task.Call,
+							Exit: res.Exit,
+						})
+						return
+					}
 				}
 			}
 
+			// Scripts in the task are canceled when one of them fails.
+			scriptCtx, cancelScripts := context.WithCancel(ctx)
+			defer cancelScripts()
+
 			// Execute one or more set in a task in parallel using Goroutine.
-			ch := make(chan taskResp)
+			ch := make(chan scriptResult, len(task.Scripts))
 			wg := sync.WaitGroup{}
 			for j := range task.Scripts {
 				wg.Add(1)
-				go func(ctx context.Context, task model.DocumentTask, script model.DocumentTaskScript, chRes chan<- taskResp) {
+				go func(ctx context.Context, task model.DocumentTask, script model.DocumentTaskScript, chRes chan<- scriptResult) {
 					defer wg.Done()
-					exit := executeTask(ctx, task, script, option)
+
+					// Wait for a free worker.
+					select {
+					case <-ctx.Done():
+						return
+					case workers <- struct{}{}:
+					}
+					res := executeTask(ctx, task, script, option)
+					<-workers
 
 					if ctx.Err() == nil {
-						chRes <- taskResp{
-							Call: task.Call,
-							Exit: exit,
-						}
+						chRes <- res
 					}
 
-				}(ctx, task, task.Scripts[j], ch)
+				}(WithScriptIndex(scriptCtx, j), task, task.Scripts[j], ch)
 			}
 			defer wg.Wait()
 
@@ -173,13 +409,22 @@ func executeTasks(ctx context.Context, document model.Document, option *executeO
 			for ended < len(task.Scripts) {
 				select {
 				case <-ctx.Done():
+					record(recorder.finish(true))
 					return
-				case result := <-ch:
+				case res := <-ch:
 					ended++
-					if ended >= len(task.Scripts) { // If all tasks are finished.
-						chRes <- result
-					} else if result.Exit != 0 { // If the script fails.
-						chRes <- result
+					recorder.add(res)
+					if ended >= len(task.Scripts) || res.Exit != 0 { // If all scripts are finished or the script fails.
+						cancelScripts()
+						if res.Exit == 0 {
+							onSucceeded(ctx, task)
+						}
+						record(recorder.finish(false))
+						send(taskResp{
+							Call: task.Call,
+							Exit: res.Exit,
+						})
+						return
 					}
 				}
 			}
@@ -188,58 +433,191 @@ func executeTasks(ctx context.Context, document model.Document, option *executeO
 
 		taskChs = append(taskChs, ch)
 	}
-	defer wg.Wait()
-	ended := 0
+
+	succeeded := []string{}
+	failed := []string{}
+	skipped := []string{}
 
 	for i := range taskChs {
 		taskChs[i] <- taskResp{}
 	}
 
 	// Wait for all tasks finish in Goroutine finish.
-	for {
-		select {
-		case <-ctx.Done():
-			return -1
-		case res := <-chTaskResp:
-			if res.Exit != 0 {
-				cancel()
-				return res.Exit
-			}
+	exit := func() int {
+		exit := 0
+		for {
+			select {
+			case <-ctx.Done():
+				return -1
+			case res := <-chTaskResp:
+				switch {
+				case res.Skipped:
+					skipped = append(skipped, res.Call)
+					logger.Warn("task skipped because dependent task failed", slog.String("task", res.Call))
+
+				case res.Exit != 0:
+					if !option.keepGoing {
+						cancel()
+						return res.Exit
+					}
 
-			ended++
-			if ended >= len(executeTasks) {
-				return 0
-			}
+					failed = append(failed, res.Call)
+					if exit == 0 {
+						exit = res.Exit
+					}
 
-			for i := range taskChs {
-				taskChs[i] <- res
+				default:
+					succeeded = append(succeeded, res.Call)
+				}
+
+				if len(succeeded)+len(failed)+len(skipped) >= len(executeTasks) {
+					if exit != 0 {
+						logger.Error("some tasks failed",
+							slog.String("failed", strings.Join(failed, ", ")),
+							slog.String("skipped", strings.Join(skipped, ", ")),
+							slog.String("succeeded", strings.Join(succeeded, ", ")),
+						)
+					}
+					return exit
+				}
+
+				for i := range taskChs {
+					taskChs[i] <- res
+				}
 			}
 		}
+	}()
+
+	// Wait for the tasks canceled, and then collect their results.
+	cancel()
+	wg.Wait()
+
+	result := ExecuteResult{ExitCode: exit}
+	for _, call := range document.SortedCalls() {
+		if !slices.Contains(executeTasks, call) {
+			continue
+		}
+
+		res, exist := results[call]
+		if !exist {
+			res = TaskResult{Call: call, Status: TaskNotRun}
+		}
+		result.Tasks = append(result.Tasks, res)
+	}
+
+	return result
+}
+
+// Execute the script, and retry it while it fails as configured in the task.
+// Only the result of the last attempt is returned.
+func executeTask(ctx context.Context, task model.DocumentTask, script model.DocumentTaskScript, option *executeOptions) scriptResult {
+	logger := GetLogger(ctx)
+	delay := task.Retry.Delay
+
+	for attempt := 1; ; attempt++ {
+		res := executeScript(WithAttempt(ctx, attempt), task, script, option)
+		res.Attempts = attempt
+		if res.Exit == 0 || attempt >= task.Retry.Attempts || !task.Retry.IsRetryable(res.Exit) || ctx.Err() != nil {
+			return res
+		}
+
+		logger.Warn("task failed, retrying",
+			slog.String("task", task.Call),
+			slog.Int("attempt", attempt),
+			slog.Int("attempts", task.Retry.Attempts),
+			slog.Int("exitCode", res.Exit),
+			slog.Duration("delay", delay),
+		)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return res
+		case <-timer.C:
+		}
+
+		if task.Retry.Backoff > 0 {
+			delay = time.Duration(float64(delay) * task.Retry.Backoff)
+		}
 	}
 }
 
-// Execute task with executeOptions
-func executeTask(ctx context.Context, task model.DocumentTask, script model.DocumentTaskScript, option *executeOptions) int {
+// Execute the script once.
+func executeScript(ctx context.Context, task model.DocumentTask, script model.DocumentTaskScript, option *executeOptions) scriptResult {
 	logger := GetLogger(ctx)
 
 	// Generate script runner with script, command, and command's args.
-	runner := srun.NewScriptRunner(script.Config.ExecPath, script.Config.CmdOpt, script.Script, script.Config.Args...)
+	var runner *srun.ScriptRunner
+	switch script.Config.Mode {
+	case model.ExecModeStdin:
+		runner = srun.NewStdinScriptRunner(script.Config.ExecPath, script.Config.CmdOpt, script.Script, script.Config.Args...)
+	case model.ExecModeFile:
+		file := srun.ScriptFile{Ext: script.Config.Ext}
+		if script.Config.Shebang {
+			file.Shebang = strings.Join(append([]string{script.Config.ExecPath}, script.Config.Args...), " ")
+		}
+		runner = srun.NewFileScriptRunner(script.Config.ExecPath, script.Config.CmdOpt, script.Script, file, script.Config.Args...)
+	default:
+		runner = srun.NewScriptRunner(script.Config.ExecPath, script.Config.CmdOpt, script.Script, script.Config.Args...)
+	}
+	runner.SetWorkingDir(task.WorkingDir())
+	if task.GracePeriod > 0 {
+		runner.SetGracePeriod(task.GracePeriod)
+	}
 
-	for key, value := range task.Envs {
+	// Same environment variables as the conditions of the task see.
+	for key, value := range task.Environ(os.Environ()) {
 		runner.SetEnv(key, value)
 	}
 
-	environ := os.Environ()
-	for i := range environ {
-		runner.SetEnviron(environ[i])
+	// Task parameters are passed as environment variables.
+	for key, value := range option.paramValues[task.Call] {
+		runner.SetEnv(key, value)
 	}
 
-	exit, err := option.onExec(ctx, task, runner)
+	// Positional arguments are passed only to the called tasks.
+	if slices.Contains(option.called, task.Call) && len(option.args) > 0 {
+		// $0 is the script filename or the interpreter in the other modes.
+		if script.Config.Arg0 && (script.Config.Mode == "" || script.Config.Mode == model.ExecModeArgument) {
+			runner.AppendArgs(task.Call)
+		}
+		runner.AppendArgs(option.args...)
+	}
+
+	// The timeout of the code block takes priority over the one of the task and the default.
+	timeout := option.timeout
+	if task.Timeout > 0 {
+		timeout = task.Timeout
+	}
+	if script.Timeout > 0 {
+		timeout = script.Timeout
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, timeout, ErrTimeout)
+		defer cancel()
+	}
+
+	exit, err := option.onExec(WithParams(ctx, option.paramValues[task.Call]), task, runner)
+
+	if errors.Is(err, ErrSkipped) {
+		return scriptResult{Skipped: true, Reason: err.Error()}
+	}
+
+	if timeout > 0 && errors.Is(context.Cause(ctx), ErrTimeout) {
+		logger.Error("task timed out", slog.String("task", task.Call), slog.Duration("timeout", timeout))
+		return scriptResult{Exit: ExitCodeTimeout}
+	}
 
 	if err != nil {
 		logger.Error("task ended with error", slog.String("task", task.Call), slog.Any("error", err))
-		return -1
+		// Keep the exit code of the script for retry conditions.
+		if exitErr := (*exec.ExitError)(nil); errors.As(err, &exitErr) && exit > 0 {
+			return scriptResult{Exit: exit}
+		}
+		return scriptResult{Exit: -1}
 	}
 
-	return exit
+	return scriptResult{Exit: exit}
 }
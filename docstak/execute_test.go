@@ -18,12 +18,20 @@ package docstak_test
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/kasaikou/markflow/docstak"
 	"github.com/kasaikou/markflow/docstak/model"
+	"github.com/kasaikou/markflow/docstak/srun"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestExecute(t *testing.T) {
@@ -179,3 +187,657 @@ func TestExecuteDepends(t *testing.T) {
 
 	docstak.ExecuteContext(ctx, document, docstak.ExecuteOptCalls("echo-parallel-4"))
 }
+
+func TestExecuteWorkers(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{}))
+	ctx := docstak.WithLogger(context.Background(), logger)
+
+	for _, numWorker := range []int{1, 2, 3} {
+		document := model.Document{
+			Tasks: map[string]model.DocumentTask{},
+		}
+		calls := []string{}
+		for i := 0; i < 8; i++ {
+			call := fmt.Sprintf("task-%d", i)
+			calls = append(calls, call)
+			document.Tasks[call] = model.DocumentTask{
+				Title: call,
+				Call:  call,
+				Scripts: []model.DocumentTaskScript{
+					{Config: model.ExecConfig{ExecPath: "sh", CmdOpt: "-c"}, Script: "true"},
+					{Config: model.ExecConfig{ExecPath: "sh", CmdOpt: "-c"}, Script: "true"},
+				},
+			}
+		}
+
+		running := atomic.Int32{}
+		maxRunning := atomic.Int32{}
+		executed := atomic.Int32{}
+
+		exit := docstak.ExecuteContext(ctx, document,
+			docstak.ExecuteOptCalls(calls...),
+			docstak.ExecuteOptWorkers(numWorker),
+			docstak.ExecuteOptProcessExec(func(ctx context.Context, task model.DocumentTask, runner *srun.ScriptRunner) (int, error) {
+				current := running.Add(1)
+				defer running.Add(-1)
+				for {
+					max := maxRunning.Load()
+					if current <= max || maxRunning.CompareAndSwap(max, current) {
+						break
+					}
+				}
+
+				time.Sleep(10 * time.Millisecond)
+				executed.Add(1)
+				return 0, nil
+			}),
+		).ExitCode
+
+		assert.Equal(t, 0, exit)
+		assert.Equal(t, int32(16), executed.Load())
+		assert.LessOrEqual(t, maxRunning.Load(), int32(numWorker), fmt.Sprintf("workers: %d", numWorker))
+	}
+}
+
+func TestExecuteInvalidWorkers(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{}))
+	ctx := docstak.WithLogger(context.Background(), logger)
+
+	document := model.Document{
+		Tasks: map[string]model.DocumentTask{
+			"build": {
+				Title: "build",
+				Call:  "build",
+				Scripts: []model.DocumentTaskScript{
+					{Config: model.ExecConfig{ExecPath: "sh", CmdOpt: "-c"}, Script: "true"},
+				},
+			},
+		},
+	}
+
+	for _, numWorker := range []int{0, -3} {
+		executed := atomic.Int32{}
+		result := docstak.ExecuteContext(ctx, document,
+			docstak.ExecuteOptCalls("build"),
+			docstak.ExecuteOptWorkers(numWorker),
+			docstak.ExecuteOptProcessExec(func(ctx context.Context, task model.DocumentTask, runner *srun.ScriptRunner) (int, error) {
+				executed.Add(1)
+				return 0, nil
+			}),
+		)
+
+		assert.Equal(t, -1, result.ExitCode, fmt.Sprintf("workers: %d", numWorker))
+		assert.Empty(t, result.Tasks)
+		assert.Equal(t, int32(0), executed.Load())
+	}
+}
+
+func TestExecuteKeepGoing(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{}))
+	ctx := docstak.WithLogger(context.Background(), logger)
+
+	script := func(code string) []model.DocumentTaskScript {
+		return []model.DocumentTaskScript{
+			{Config: model.ExecConfig{ExecPath: "sh", CmdOpt: "-c"}, Script: code},
+		}
+	}
+
+	document := model.Document{
+		Tasks: map[string]model.DocumentTask{
+			"fail": {
+				Title:   "fail",
+				Call:    "fail",
+				Scripts: script("exit 3"),
+			},
+			"after-fail": {
+				Title:       "after-fail",
+				Call:        "after-fail",
+				Scripts:     script("exit 0"),
+				DependTasks: []string{"fail"},
+			},
+			"after-after-fail": {
+				Title:       "after-after-fail",
+				Call:        "after-after-fail",
+				Scripts:     script("exit 0"),
+				DependTasks: []string{"after-fail"},
+			},
+			"independent": {
+				Title:   "independent",
+				Call:    "independent",
+				Scripts: script("sleep 0.1"),
+			},
+			"after-independent": {
+				Title:       "after-independent",
+				Call:        "after-independent",
+				Scripts:     script("exit 0"),
+				DependTasks: []string{"independent"},
+			},
+		},
+	}
+
+	executed := map[string]int{}
+	mu := sync.Mutex{}
+	onExec := docstak.ExecuteOptProcessExec(func(ctx context.Context, task model.DocumentTask, runner *srun.ScriptRunner) (int, error) {
+		exit, err := runner.RunContext(ctx)
+		mu.Lock()
+		defer mu.Unlock()
+		executed[task.Call] = exit
+		return exit, err
+	})
+
+	exit := docstak.ExecuteContext(ctx, document,
+		docstak.ExecuteOptCalls("after-after-fail", "after-independent"),
+		docstak.ExecuteOptKeepGoing(true),
+		onExec,
+	).ExitCode
+
+	assert.NotEqual(t, 0, exit)
+	assert.Equal(t, map[string]int{
+		"fail":              3,
+		"independent":       0,
+		"after-independent": 0,
+	}, executed)
+}
+
+func TestExecuteSequentialScripts(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{}))
+	ctx := docstak.WithLogger(context.Background(), logger)
+
+	document := model.Document{
+		Tasks: map[string]model.DocumentTask{
+			"sequential": {
+				Title: "sequential",
+				Call:  "sequential",
+				Scripts: []model.DocumentTaskScript{
+					{Config: model.ExecConfig{ExecPath: "sh", CmdOpt: "-c"}, Script: "sleep 0.05"},
+					{Config: model.ExecConfig{ExecPath: "sh", CmdOpt: "-c"}, Script: "exit 0"},
+					{Config: model.ExecConfig{ExecPath: "sh", CmdOpt: "-c"}, Script: "exit 1"},
+					{Config: model.ExecConfig{ExecPath: "sh", CmdOpt: "-c"}, Script: "exit 0"},
+				},
+			},
+		},
+	}
+
+	executed := []int{}
+	mu := sync.Mutex{}
+	exit := docstak.ExecuteContext(ctx, document,
+		docstak.ExecuteOptCalls("sequential"),
+		docstak.ExecuteOptProcessExec(func(ctx context.Context, task model.DocumentTask, runner *srun.ScriptRunner) (int, error) {
+			idx, exist := docstak.GetScriptIndex(ctx)
+			assert.True(t, exist)
+			mu.Lock()
+			executed = append(executed, idx)
+			mu.Unlock()
+			return runner.RunContext(ctx)
+		}),
+	).ExitCode
+
+	assert.NotEqual(t, 0, exit)
+	assert.Equal(t, []int{0, 1, 2}, executed)
+}
+
+func TestExecuteParallelScripts(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{}))
+	ctx := docstak.WithLogger(context.Background(), logger)
+
+	document := model.Document{
+		Tasks: map[string]model.DocumentTask{
+			"parallel": {
+				Title: "parallel",
+				Call:  "parallel",
+				Scripts: []model.DocumentTaskScript{
+					{Config: model.ExecConfig{ExecPath: "sh", CmdOpt: "-c"}, Script: "true"},
+					{Config: model.ExecConfig{ExecPath: "sh", CmdOpt: "-c"}, Script: "true"},
+					{Config: model.ExecConfig{ExecPath: "sh", CmdOpt: "-c"}, Script: "true"},
+				},
+				Parallel: true,
+			},
+		},
+	}
+
+	running := atomic.Int32{}
+	maxRunning := atomic.Int32{}
+	exit := docstak.ExecuteContext(ctx, document,
+		docstak.ExecuteOptCalls("parallel"),
+		docstak.ExecuteOptWorkers(3),
+		docstak.ExecuteOptProcessExec(func(ctx context.Context, task model.DocumentTask, runner *srun.ScriptRunner) (int, error) {
+			current := running.Add(1)
+			defer running.Add(-1)
+			for {
+				max := maxRunning.Load()
+				if current <= max || maxRunning.CompareAndSwap(max, current) {
+					break
+				}
+			}
+
+			time.Sleep(50 * time.Millisecond)
+			return 0, nil
+		}),
+	).ExitCode
+
+	assert.Equal(t, 0, exit)
+	assert.Equal(t, int32(3), maxRunning.Load())
+}
+
+func TestExecuteUnknownTask(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{}))
+	ctx := docstak.WithLogger(context.Background(), logger)
+
+	document := model.Document{
+		Tasks: map[string]model.DocumentTask{
+			"echo": {
+				Title: "echo",
+				Call:  "echo",
+				Scripts: []model.DocumentTaskScript{
+					{Config: model.ExecConfig{ExecPath: "sh", CmdOpt: "-c"}, Script: "echo 'hello world'"},
+				},
+			},
+		},
+	}
+
+	executed := atomic.Int32{}
+	exit := docstak.ExecuteContext(ctx, document,
+		docstak.ExecuteOptCalls("echo", "ecko"),
+		docstak.ExecuteOptProcessExec(func(ctx context.Context, task model.DocumentTask, runner *srun.ScriptRunner) (int, error) {
+			executed.Add(1)
+			return 0, nil
+		}),
+	).ExitCode
+
+	assert.NotEqual(t, 0, exit)
+	assert.Equal(t, int32(0), executed.Load())
+}
+
+func TestExecuteParams(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{}))
+	ctx := docstak.WithLogger(context.Background(), logger)
+
+	output := filepath.Join(t.TempDir(), "output.txt")
+	document := model.Document{
+		Tasks: map[string]model.DocumentTask{
+			"deploy": {
+				Title: "deploy",
+				Call:  "deploy",
+				Scripts: []model.DocumentTaskScript{
+					{
+						Config: model.ExecConfig{ExecPath: "sh", CmdOpt: "-c", Arg0: true},
+						Script: `echo "$0 $env $region $@" > "$OUTPUT"`,
+					},
+				},
+				Envs: map[string]string{"OUTPUT": output},
+				Params: []model.TaskParam{
+					{Name: "env", Required: true},
+					{Name: "region", Default: "ap-northeast-1"},
+				},
+			},
+		},
+	}
+
+	exit := docstak.ExecuteContext(ctx, document,
+		docstak.ExecuteOptCalls("deploy"),
+		docstak.ExecuteOptParams("deploy", map[string]string{"env": "staging"}),
+		docstak.ExecuteOptArgs("--extra", "args"),
+	).ExitCode
+	assert.Equal(t, 0, exit)
+
+	b, err := os.ReadFile(output)
+	assert.NoError(t, err)
+	assert.Equal(t, "deploy staging ap-northeast-1 --extra args\n", string(b))
+
+	// Required parameter is not given.
+	exit = docstak.ExecuteContext(ctx, document, docstak.ExecuteOptCalls("deploy")).ExitCode
+	assert.NotEqual(t, 0, exit)
+}
+
+func TestExecuteScriptFile(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{}))
+	ctx := docstak.WithLogger(context.Background(), logger)
+
+	output := filepath.Join(t.TempDir(), "output.txt")
+	document := model.Document{
+		Tasks: map[string]model.DocumentTask{
+			"script": {
+				Title: "script",
+				Call:  "script",
+				Scripts: []model.DocumentTaskScript{
+					{
+						Config: model.ExecConfig{ExecPath: "/bin/sh", Mode: model.ExecModeFile, Ext: ".sh", Arg0: true},
+						Script: `echo "$(basename "$0" .sh | cut -c -8) $@" > "$OUTPUT"`,
+					},
+				},
+				Envs: map[string]string{"OUTPUT": output},
+			},
+		},
+	}
+
+	exit := docstak.ExecuteContext(ctx, document,
+		docstak.ExecuteOptCalls("script"),
+		docstak.ExecuteOptArgs("--extra", "args"),
+	).ExitCode
+	assert.Equal(t, 0, exit)
+
+	b, err := os.ReadFile(output)
+	assert.NoError(t, err)
+	assert.Equal(t, "docstak- --extra args\n", string(b))
+}
+
+func TestExecuteTimeout(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{}))
+	ctx := docstak.WithLogger(context.Background(), logger)
+
+	newDocument := func(taskTimeout, scriptTimeout time.Duration) model.Document {
+		return model.Document{
+			Tasks: map[string]model.DocumentTask{
+				"hang": {
+					Title: "hang",
+					Call:  "hang",
+					Scripts: []model.DocumentTaskScript{
+						{
+							Config:  model.ExecConfig{ExecPath: "sh", CmdOpt: "-c"},
+							Script:  "sleep 10",
+							Timeout: scriptTimeout,
+						},
+					},
+					Timeout: taskTimeout,
+				},
+			},
+		}
+	}
+
+	cases := map[string]struct {
+		document model.Document
+		options  []docstak.ExecuteOption
+	}{
+		"task":    {document: newDocument(100*time.Millisecond, 0)},
+		"block":   {document: newDocument(time.Hour, 100*time.Millisecond)},
+		"default": {document: newDocument(0, 0), options: []docstak.ExecuteOption{docstak.ExecuteOptTimeout(100 * time.Millisecond)}},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			start := time.Now()
+			exit := docstak.ExecuteContext(ctx, c.document, append(c.options, docstak.ExecuteOptCalls("hang"))...).ExitCode
+			assert.Equal(t, docstak.ExitCodeTimeout, exit)
+			assert.Less(t, time.Since(start), 5*time.Second)
+		})
+	}
+
+	// The task finished in time.
+	document := newDocument(0, 0)
+	task := document.Tasks["hang"]
+	task.Scripts[0].Script = "true"
+	task.Timeout = time.Hour
+	document.Tasks["hang"] = task
+	assert.Equal(t, 0, docstak.ExecuteContext(ctx, document, docstak.ExecuteOptCalls("hang")).ExitCode)
+}
+
+func TestExecuteRetry(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{}))
+	ctx := docstak.WithLogger(context.Background(), logger)
+
+	// The script fails until it runs for the given times, with the exit code 3.
+	newDocument := func(count string, succeedAt int, retry model.TaskRetry) model.Document {
+		return model.Document{
+			Tasks: map[string]model.DocumentTask{
+				"flaky": {
+					Title: "flaky",
+					Call:  "flaky",
+					Scripts: []model.DocumentTaskScript{
+						{
+							Config: model.ExecConfig{ExecPath: "sh", CmdOpt: "-c"},
+							Script: fmt.Sprintf(`n=$(($(cat "$COUNT" 2>/dev/null || echo 0) + 1)); echo $n > "$COUNT"; [ $n -ge %d ] || exit 3`, succeedAt),
+						},
+					},
+					Envs:  map[string]string{"COUNT": count},
+					Retry: retry,
+				},
+				"next": {
+					Title:       "next",
+					Call:        "next",
+					DependTasks: []string{"flaky"},
+				},
+			},
+		}
+	}
+
+	readCount := func(count string) string {
+		b, err := os.ReadFile(count)
+		assert.NoError(t, err)
+		return strings.TrimSpace(string(b))
+	}
+
+	t.Run("succeeded", func(t *testing.T) {
+		count := filepath.Join(t.TempDir(), "count")
+		attempts := []int{}
+		mu := sync.Mutex{}
+
+		start := time.Now()
+		exit := docstak.ExecuteContext(ctx, newDocument(count, 3, model.TaskRetry{Attempts: 3, Delay: 50 * time.Millisecond, Backoff: 2}),
+			docstak.ExecuteOptCalls("next"),
+			docstak.ExecuteOptProcessExec(func(ctx context.Context, task model.DocumentTask, runner *srun.ScriptRunner) (int, error) {
+				attempt, _ := docstak.GetAttempt(ctx)
+				mu.Lock()
+				attempts = append(attempts, attempt)
+				mu.Unlock()
+				return runner.RunContext(ctx)
+			}),
+		).ExitCode
+		assert.Equal(t, 0, exit)
+		assert.Equal(t, "3", readCount(count))
+		assert.Equal(t, []int{1, 2, 3}, attempts)
+		// Wait for 50ms and then 100ms.
+		assert.GreaterOrEqual(t, time.Since(start), 150*time.Millisecond)
+	})
+
+	t.Run("exhausted", func(t *testing.T) {
+		count := filepath.Join(t.TempDir(), "count")
+		checked := 0
+
+		// The task is checked only before the first attempt.
+		exit := docstak.ExecuteContext(ctx, newDocument(count, 3, model.TaskRetry{Attempts: 2}),
+			docstak.ExecuteOptCalls("next"),
+			docstak.ExecuteOptTaskCheck(func(ctx context.Context, task model.DocumentTask) error {
+				checked++
+				return nil
+			}),
+		).ExitCode
+		assert.Equal(t, 3, exit)
+		assert.Equal(t, "2", readCount(count))
+		assert.Equal(t, 1, checked)
+	})
+
+	t.Run("exit code not matched", func(t *testing.T) {
+		count := filepath.Join(t.TempDir(), "count")
+		exit := docstak.ExecuteContext(ctx, newDocument(count, 3, model.TaskRetry{Attempts: 3, OnExitCodes: []int{1, 2}}), docstak.ExecuteOptCalls("flaky")).ExitCode
+		assert.Equal(t, 3, exit)
+		assert.Equal(t, "1", readCount(count))
+	})
+
+	t.Run("exit code matched", func(t *testing.T) {
+		count := filepath.Join(t.TempDir(), "count")
+		exit := docstak.ExecuteContext(ctx, newDocument(count, 2, model.TaskRetry{Attempts: 3, OnExitCodes: []int{3}}), docstak.ExecuteOptCalls("flaky")).ExitCode
+		assert.Equal(t, 0, exit)
+		assert.Equal(t, "2", readCount(count))
+	})
+}
+
+func TestExecuteResults(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{}))
+	ctx := docstak.WithLogger(context.Background(), logger)
+
+	newTask := func(index int, call string, script string, depends ...string) model.DocumentTask {
+		return model.DocumentTask{
+			Index:       index,
+			Title:       call,
+			Call:        call,
+			DependTasks: depends,
+			Scripts: []model.DocumentTaskScript{
+				{Config: model.ExecConfig{ExecPath: "sh", CmdOpt: "-c"}, Script: script},
+			},
+		}
+	}
+
+	document := model.Document{
+		Tasks: map[string]model.DocumentTask{
+			"build":  newTask(0, "build", "true"),
+			"cached": newTask(1, "cached", "exit 1"),
+			"test":   newTask(2, "test", "sleep 0.2; exit 2", "build", "cached"),
+			"deploy": newTask(3, "deploy", "true", "test"),
+			"serve":  newTask(4, "serve", "sleep 10"),
+		},
+	}
+
+	result := docstak.ExecuteContext(ctx, document,
+		docstak.ExecuteOptCalls("deploy", "serve"),
+		docstak.ExecuteOptWorkers(5),
+		docstak.ExecuteOptProcessExec(func(ctx context.Context, task model.DocumentTask, runner *srun.ScriptRunner) (int, error) {
+			if task.Call == "cached" {
+				return 0, docstak.SkipError("up to date")
+			}
+			return runner.RunContext(ctx)
+		}),
+	)
+
+	assert.Equal(t, 2, result.ExitCode)
+	if !assert.Len(t, result.Tasks, 5) {
+		return
+	}
+
+	expects := []struct {
+		call     string
+		status   docstak.TaskStatus
+		exitCode int
+		attempts int
+		reason   string
+	}{
+		{call: "build", status: docstak.TaskSucceeded, attempts: 1},
+		{call: "cached", status: docstak.TaskSkipped, attempts: 1, reason: "up to date"},
+		{call: "test", status: docstak.TaskFailed, exitCode: 2, attempts: 1},
+		{call: "deploy", status: docstak.TaskNotRun},
+		{call: "serve", status: docstak.TaskCancelled, attempts: 1},
+	}
+	for i, expect := range expects {
+		res := result.Tasks[i]
+		assert.Equal(t, expect.call, res.Call)
+		assert.Equal(t, expect.status, res.Status, res.Call)
+		assert.Equal(t, expect.attempts, res.Attempts, res.Call)
+		assert.Equal(t, expect.reason, res.Reason, res.Call)
+		if expect.status != docstak.TaskCancelled {
+			assert.Equal(t, expect.exitCode, res.ExitCode, res.Call)
+		}
+	}
+	assert.GreaterOrEqual(t, result.Tasks[2].Duration, 200*time.Millisecond)
+}
+
+func TestExecuteTaskCheck(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{}))
+	ctx := docstak.WithLogger(context.Background(), logger)
+
+	newTask := func(index int, call string, depends ...string) model.DocumentTask {
+		return model.DocumentTask{
+			Index:       index,
+			Title:       call,
+			Call:        call,
+			DependTasks: depends,
+			Scripts: []model.DocumentTaskScript{
+				{Config: model.ExecConfig{ExecPath: "sh", CmdOpt: "-c"}, Script: "true"},
+				{Config: model.ExecConfig{ExecPath: "sh", CmdOpt: "-c"}, Script: "true"},
+			},
+		}
+	}
+
+	document := model.Document{
+		Tasks: map[string]model.DocumentTask{
+			"build":  newTask(0, "build"),
+			"cached": newTask(1, "cached"),
+			"deploy": newTask(2, "deploy", "build", "cached"),
+			"secret": newTask(3, "secret"),
+			"notify": newTask(4, "notify", "secret"),
+		},
+	}
+
+	checked := map[string]int{}
+	ran := map[string]int{}
+	mu := sync.Mutex{}
+
+	result := docstak.ExecuteContext(ctx, document,
+		docstak.ExecuteOptCalls("deploy", "notify"),
+		docstak.ExecuteOptKeepGoing(true),
+		docstak.ExecuteOptTaskCheck(func(ctx context.Context, task model.DocumentTask) error {
+			mu.Lock()
+			defer mu.Unlock()
+			checked[task.Call]++
+
+			switch task.Call {
+			case "cached":
+				return docstak.SkipError("up to date")
+			case "secret":
+				return fmt.Errorf("missing credentials")
+			}
+			return nil
+		}),
+		docstak.ExecuteOptProcessExec(func(ctx context.Context, task model.DocumentTask, runner *srun.ScriptRunner) (int, error) {
+			mu.Lock()
+			ran[task.Call]++
+			mu.Unlock()
+			return runner.RunContext(ctx)
+		}),
+	)
+
+	assert.Equal(t, -1, result.ExitCode)
+	assert.Equal(t, map[string]int{"build": 1, "cached": 1, "deploy": 1, "secret": 1}, checked)
+	assert.Equal(t, map[string]int{"build": 2, "deploy": 2}, ran)
+
+	statuses := map[string]docstak.TaskStatus{}
+	for _, res := range result.Tasks {
+		statuses[res.Call] = res.Status
+	}
+	assert.Equal(t, map[string]docstak.TaskStatus{
+		"build":  docstak.TaskSucceeded,
+		"cached": docstak.TaskSkipped,
+		"deploy": docstak.TaskSucceeded,
+		"secret": docstak.TaskFailed,
+		"notify": docstak.TaskNotRun,
+	}, statuses)
+}
+
+func TestExecuteTaskSucceeded(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{}))
+	ctx := docstak.WithLogger(context.Background(), logger)
+
+	newTask := func(index int, call string, parallel bool, scripts ...string) model.DocumentTask {
+		task := model.DocumentTask{Index: index, Title: call, Call: call, Parallel: parallel}
+		for i := range scripts {
+			task.Scripts = append(task.Scripts, model.DocumentTaskScript{
+				Config: model.ExecConfig{ExecPath: "sh", CmdOpt: "-c"},
+				Script: scripts[i],
+			})
+		}
+		return task
+	}
+
+	document := model.Document{
+		Tasks: map[string]model.DocumentTask{
+			"sequential":        newTask(0, "sequential", false, "true", "true"),
+			"sequential-failed": newTask(1, "sequential-failed", false, "true", "exit 1"),
+			"parallel":          newTask(2, "parallel", true, "true", "sleep 0.1"),
+			// The block with the highest index succeeds after the other one failed.
+			"parallel-failed": newTask(3, "parallel-failed", true, "exit 1", "sleep 0.1"),
+		},
+	}
+
+	succeeded := []string{}
+	mu := sync.Mutex{}
+	docstak.ExecuteContext(ctx, document,
+		docstak.ExecuteOptCalls("sequential", "sequential-failed", "parallel", "parallel-failed"),
+		docstak.ExecuteOptWorkers(4),
+		docstak.ExecuteOptKeepGoing(true),
+		docstak.ExecuteOptTaskSucceeded(func(ctx context.Context, task model.DocumentTask) {
+			mu.Lock()
+			defer mu.Unlock()
+			succeeded = append(succeeded, task.Call)
+		}),
+	)
+
+	assert.ElementsMatch(t, []string{"sequential", "parallel"}, succeeded)
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2024 Kasai Kou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package markdown
+
+import (
+	"reflect"
+
+	"gopkg.in/yaml.v3"
+)
+
+var yamlUnmarshalerType = reflect.TypeOf((*yaml.Unmarshaler)(nil)).Elem()
+
+// Returns JSON Schema of the yaml config blocks (```yaml:docstak.yml).
+// The block before the first heading is the global config, and the others are the task config.
+func ConfigJSONSchema() map[string]any {
+	return map[string]any{
+		"$schema": "http://json-schema.org/draft-07/schema#",
+		"title":   "docstak.yml",
+		"anyOf": []any{
+			map[string]any{"$ref": "#/definitions/global"},
+			map[string]any{"$ref": "#/definitions/task"},
+		},
+		"definitions": map[string]any{
+			"global": jsonSchemaOf(reflect.TypeOf(ParseResultGlobalConfig{})),
+			"task":   jsonSchemaOf(reflect.TypeOf(ParseResultTaskConfig{})),
+		},
+	}
+}
+
+func jsonSchemaOf(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		properties := map[string]any{}
+		for name, field := range yamlFields(t) {
+			properties[name] = jsonSchemaOf(field.Type)
+		}
+
+		schema := map[string]any{
+			"type":                 "object",
+			"properties":           properties,
+			"additionalProperties": false,
+		}
+
+		// Types decoding themselves accept a string as the short form.
+		if reflect.PointerTo(t).Implements(yamlUnmarshalerType) {
+			return map[string]any{
+				"oneOf": []any{map[string]any{"type": "string"}, schema},
+			}
+		}
+
+		return schema
+
+	case reflect.Slice, reflect.Array:
+		return map[string]any{
+			"type":  "array",
+			"items": jsonSchemaOf(t.Elem()),
+		}
+
+	case reflect.Map:
+		return map[string]any{
+			"type":                 "object",
+			"additionalProperties": jsonSchemaOf(t.Elem()),
+		}
+
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+
+	case reflect.String:
+		return map[string]any{"type": "string"}
+
+	default:
+		return map[string]any{}
+	}
+}
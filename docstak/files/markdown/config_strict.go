@@ -0,0 +1,148 @@
+/*
+Copyright 2024 Kasai Kou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package markdown
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/kasaikou/markflow/docstak/model"
+	"gopkg.in/yaml.v3"
+)
+
+// Returned when the config block has a key which is not defined.
+type UnknownKeyError struct {
+	Position   ParsePosition
+	Key        string
+	Suggestion string
+}
+
+func (e *UnknownKeyError) Error() string {
+	if e.Suggestion == "" {
+		return fmt.Sprintf("unknown key '%s'", e.Key)
+	}
+
+	return fmt.Sprintf("unknown key '%s' (did you mean '%s'?)", e.Key, e.Suggestion)
+}
+
+// Decodes the yaml config block, and returns error when it has unknown keys.
+// The position is used to report the location of the key in the markdown file.
+func decodeConfigStrict(code []byte, out any, position ParsePosition) error {
+	node := yaml.Node{}
+	if err := yaml.Unmarshal(code, &node); err != nil {
+		return err
+	}
+
+	// Empty block.
+	if node.Kind == 0 || len(node.Content) == 0 {
+		return nil
+	}
+
+	if err := validateKnownKeys(node.Content[0], reflect.TypeOf(out).Elem(), "", position); err != nil {
+		return err
+	}
+
+	return node.Content[0].Decode(out)
+}
+
+func validateKnownKeys(node *yaml.Node, t reflect.Type, path string, position ParsePosition) error {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		// Leave type mismatches to the decoder.
+		// Some types decoding themselves accept other than mapping.
+		if node.Kind != yaml.MappingNode {
+			return nil
+		}
+
+		fields := yamlFields(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i].Value
+			field, exist := fields[key]
+			if !exist {
+				candidates := make([]string, 0, len(fields))
+				for name := range fields {
+					candidates = append(candidates, name)
+				}
+
+				err := &UnknownKeyError{
+					Position: ParsePosition{
+						Line:   position.Line + node.Content[i].Line - 1,
+						Column: position.Column + node.Content[i].Column - 1,
+					},
+					Key: path + key,
+				}
+				if suggestion := model.SuggestClosest(key, candidates); suggestion != "" {
+					err.Suggestion = path + suggestion
+				}
+
+				return err
+			}
+
+			if err := validateKnownKeys(node.Content[i+1], field.Type, path+key+".", position); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Slice:
+		if node.Kind != yaml.SequenceNode {
+			return nil
+		}
+		for i := range node.Content {
+			if err := validateKnownKeys(node.Content[i], t.Elem(), path, position); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			return nil
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if err := validateKnownKeys(node.Content[i+1], t.Elem(), path+node.Content[i].Value+".", position); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Returns fields of the struct keyed by the name in yaml tag.
+func yamlFields(t reflect.Type) map[string]reflect.StructField {
+	fields := map[string]reflect.StructField{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag, exist := field.Tag.Lookup("yaml")
+		if !exist {
+			continue
+		}
+
+		name, _, _ := strings.Cut(tag, ",")
+		if name == "" || name == "-" {
+			continue
+		}
+
+		fields[name] = field
+	}
+
+	return fields
+}
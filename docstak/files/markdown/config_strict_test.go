@@ -0,0 +1,88 @@
+/*
+Copyright 2024 Kasai Kou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package markdown
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeConfigStrict(t *testing.T) {
+	tests := []struct {
+		Code   string
+		Expect *UnknownKeyError
+	}{
+		{
+			Code: "previous: [download]\nskips:\n  file:\n    exist: [go.sum]\n",
+		},
+		{
+			Code:   "previos: [download]\n",
+			Expect: &UnknownKeyError{Position: ParsePosition{Line: 10, Column: 1}, Key: "previos", Suggestion: "previous"},
+		},
+		{
+			Code:   "skips:\n  fle:\n    exist: [go.sum]\n",
+			Expect: &UnknownKeyError{Position: ParsePosition{Line: 11, Column: 3}, Key: "skips.fle", Suggestion: "skips.file"},
+		},
+		{
+			Code:   "skip:\n  file:\n    exist: [go.sum]\n",
+			Expect: &UnknownKeyError{Position: ParsePosition{Line: 10, Column: 1}, Key: "skip", Suggestion: "skips"},
+		},
+		{
+			Code:   "environ:\n  vars:\n    FOO: bar\n  unrelated: true\n",
+			Expect: &UnknownKeyError{Position: ParsePosition{Line: 13, Column: 3}, Key: "environ.unrelated"},
+		},
+	}
+
+	for _, test := range tests {
+		config := ParseResultTaskConfig{}
+		err := decodeConfigStrict([]byte(test.Code), &config, ParsePosition{Line: 10, Column: 1})
+		if test.Expect == nil {
+			assert.NoError(t, err)
+			continue
+		}
+
+		unknownKey := (*UnknownKeyError)(nil)
+		if assert.True(t, errors.As(err, &unknownKey), test.Code) {
+			assert.Equal(t, test.Expect, unknownKey)
+		}
+	}
+}
+
+func TestParseMarkdownUnknownKey(t *testing.T) {
+	markdown := MarkdownOption{
+		filename: "docstak.md",
+		bytes:    []byte("# root\n\n## test\n\n```yaml:docstak.yml\nprevios: [download]\n```\n"),
+	}
+
+	_, err := ParseMarkdown(context.Background(), markdown)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "docstak.md:6:1:")
+		assert.Contains(t, err.Error(), "did you mean 'previous'?")
+	}
+}
+
+func TestConfigJSONSchema(t *testing.T) {
+	schema := ConfigJSONSchema()
+	definitions := schema["definitions"].(map[string]any)
+	task := definitions["task"].(map[string]any)
+	assert.Equal(t, false, task["additionalProperties"])
+	assert.Contains(t, task["properties"], "previous")
+	assert.Contains(t, definitions["global"].(map[string]any)["properties"], "include")
+}
@@ -19,36 +19,97 @@ package markdown
 import (
 	"context"
 	"log/slog"
+	"maps"
 	"os"
-	"path"
+	"os/exec"
 	"path/filepath"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/cockroachdb/errors"
 	"github.com/kasaikou/markflow/docstak"
 	"github.com/kasaikou/markflow/docstak/environ"
 	"github.com/kasaikou/markflow/docstak/model"
+	"github.com/kasaikou/markflow/docstak/resolver"
 )
 
-func setDocumentTask(ctx context.Context, document *model.DocumentConfig, result ParseResultTask) error {
+func setDocumentTask(ctx context.Context, document *model.DocumentConfig, filename string, result ParseResultTask) error {
 	name := result.Title
+	source := model.SourcePosition{
+		Filename: filename,
+		Line:     result.Position.Line,
+		Column:   result.Position.Column,
+	}
 
-	if _, exist := document.Document.Tasks[name]; exist {
-		return errors.Errorf("duplicated task: '%s'", name)
+	if exist, duplicated := document.Document.Tasks[name]; duplicated {
+		return source.WrapError(errors.Errorf("duplicated task: '%s' (previously defined at %s)", name, exist.Source))
 	}
 
 	config := model.DocumentTask{
-		Parent:      &document.Document,
-		Title:       result.Title,
-		Call:        name,
-		Description: result.Description,
-		Envs:        make(map[string]string),
-		DependTasks: result.Config.Previous,
+		Parent:       &document.Document,
+		Index:        len(document.Document.Tasks),
+		Source:       source,
+		HeadingLevel: result.HeadingLevel,
+		Title:        result.Title,
+		Call:         name,
+		Description:  result.Description,
+		Envs:         make(map[string]string),
+		DependTasks:  result.Config.Previous,
+		Parallel:     result.Config.Parallel,
+		Workdir:      document.Document.Rootdir,
+	}
+
+	// Scripts run in the working directory relative to the root directory.
+	if result.Config.Workdir != "" {
+		if filepath.IsAbs(result.Config.Workdir) {
+			config.Workdir = result.Config.Workdir
+		} else {
+			config.Workdir = filepath.Join(document.Document.Rootdir, result.Config.Workdir)
+		}
+	}
+
+	if result.Config.GracePeriod != "" {
+		gracePeriod, err := time.ParseDuration(result.Config.GracePeriod)
+		if err != nil {
+			return source.WrapError(errors.WithMessage(err, "invalid grace-period"))
+		}
+		config.GracePeriod = gracePeriod
+	}
+
+	if result.Config.Timeout != "" {
+		timeout, err := time.ParseDuration(result.Config.Timeout)
+		if err != nil {
+			return source.WrapError(errors.WithMessage(err, "invalid timeout"))
+		}
+		config.Timeout = timeout
+	}
+
+	if retry := result.Config.Retry; retry != nil {
+		if retry.Attempts < 1 {
+			return source.WrapError(errors.Errorf("retry attempts must be positive (have: %d)", retry.Attempts))
+		}
+		if retry.Backoff < 0 {
+			return source.WrapError(errors.Errorf("retry backoff must not be negative (have: %g)", retry.Backoff))
+		}
+		config.Retry = model.TaskRetry{
+			Attempts:    retry.Attempts,
+			Backoff:     retry.Backoff,
+			OnExitCodes: retry.OnExitCodes,
+		}
+		if retry.Delay != "" {
+			delay, err := time.ParseDuration(retry.Delay)
+			if err != nil {
+				return source.WrapError(errors.WithMessage(err, "invalid retry delay"))
+			}
+			config.Retry.Delay = delay
+		}
 	}
 
 	// Read dotenv files.
 	for i := range result.Config.Environ.Dotenvs {
-		if !path.IsAbs(result.Config.Environ.Dotenvs[i]) {
-			result.Config.Environ.Dotenvs[i] = path.Join(document.Document.Rootdir, result.Config.Environ.Dotenvs[i])
+		if !filepath.IsAbs(result.Config.Environ.Dotenvs[i]) {
+			result.Config.Environ.Dotenvs[i] = filepath.Join(config.Workdir, result.Config.Environ.Dotenvs[i])
 		}
 		err := environ.LoadDotenv(result.Config.Environ.Dotenvs[i], func(key, value string) {
 			config.Envs[key] = value
@@ -57,7 +118,7 @@ func setDocumentTask(ctx context.Context, document *model.DocumentConfig, result
 			if os.IsNotExist(err) {
 				docstak.GetLogger(ctx).Warn("dotenv file not found", slog.String("filename", result.Config.Environ.Dotenvs[i]))
 			} else {
-				return err
+				return source.WrapError(err)
 			}
 		}
 	}
@@ -67,8 +128,19 @@ func setDocumentTask(ctx context.Context, document *model.DocumentConfig, result
 	}
 
 	config.Requires.ExistPaths = result.Config.Requires.File.Exists
+	config.Requires.Env = model.TaskEnvCondition{Set: result.Config.Requires.Env.Set, Equals: result.Config.Requires.Env.Equals}
+	if len(result.Config.Requires.File.NewerThans) > 0 {
+		return source.WrapError(errors.New("newer-than is available only in skips"))
+	}
 
 	config.Skips.ExistPaths = result.Config.Skips.File.Exists
+	config.Skips.GeneratePaths = result.Config.Skips.Generates
+	config.Skips.NewerThanPaths = result.Config.Skips.File.NewerThans
+	config.Skips.ExecVersion = result.Config.Skips.ExecVersion
+	config.Skips.Env = model.TaskEnvCondition{Set: result.Config.Skips.Env.Set, Equals: result.Config.Skips.Env.Equals}
+	if len(config.Skips.NewerThanPaths) > 0 && len(config.Skips.GeneratePaths) == 0 {
+		return source.WrapError(errors.New("skips.file.newer-than requires skips.generates to compare with"))
+	}
 	if len(result.Config.Skips.File.NotChangeds) > 0 {
 		config.Skips.NotChangedPaths = append(config.Skips.NotChangedPaths, model.TaskFileNotChangedCondition{
 			Paths: map[string]struct{}{},
@@ -78,66 +150,300 @@ func setDocumentTask(ctx context.Context, document *model.DocumentConfig, result
 		}
 	}
 
+	for _, param := range result.Config.Params {
+		config.Params = append(config.Params, model.TaskParam{
+			Name:     param.Name,
+			Default:  param.Default,
+			Required: param.Required,
+			Allowed:  param.Allowed,
+		})
+	}
+
 	for i := range result.Commands {
+		commandSource := model.SourcePosition{
+			Filename: filename,
+			Line:     result.Commands[i].Position.Line,
+			Column:   result.Commands[i].Position.Column,
+		}
 		execConfig, exist := document.ExecPathResolver[result.Commands[i].Lang]
 		if !exist {
-			return errors.Errorf("cannot resolve execute path in defined script language '%s'", result.Commands[i].Lang)
+			return commandSource.WrapError(errors.Errorf("cannot resolve execute path in defined script language '%s'", result.Commands[i].Lang))
+		}
+
+		// The interpreter in the info string like 'sh:/bin/bash' takes priority.
+		if result.Commands[i].Interpreter != "" {
+			execPath, err := exec.LookPath(result.Commands[i].Interpreter)
+			if err != nil {
+				return commandSource.WrapError(errors.WithMessagef(err, "cannot resolve interpreter '%s'", result.Commands[i].Interpreter))
+			}
+			execConfig.ExecPath = execPath
 		}
 
-		config.Scripts = append(config.Scripts, model.DocumentTaskScript{
+		script := model.DocumentTaskScript{
 			Config: execConfig,
 			Script: result.Commands[i].Code,
-		})
+		}
+		if result.Commands[i].Timeout != "" {
+			timeout, err := time.ParseDuration(result.Commands[i].Timeout)
+			if err != nil {
+				return commandSource.WrapError(errors.WithMessage(err, "invalid timeout"))
+			}
+			script.Timeout = timeout
+		}
+
+		config.Scripts = append(config.Scripts, script)
+	}
+
+	if len(result.Config.Matrix) > 0 {
+		return source.WrapError(setMatrixTasks(document, config, result.Config.Matrix))
 	}
 
 	document.Document.Tasks[name] = config
 	return nil
 }
 
+// Expand the task into instances for every combination of the matrix values.
+// The task itself depends on all instances, so that depending on it waits for every instance.
+func setMatrixTasks(document *model.DocumentConfig, config model.DocumentTask, matrix map[string][]string) error {
+	keys := make([]string, 0, len(matrix))
+	for key := range matrix {
+		if len(matrix[key]) == 0 {
+			return errors.Errorf("matrix '%s' of task '%s' has no values", key, config.Call)
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	base := model.DocumentTask{
+		Parent:       config.Parent,
+		Index:        config.Index,
+		Source:       config.Source,
+		HeadingLevel: config.HeadingLevel,
+		Title:        config.Title,
+		Call:         config.Call,
+		Description:  config.Description,
+	}
+
+	for i, values := range matrixCombinations(keys, matrix) {
+		name := matrixTaskName(config.Call, keys, values)
+		if exist, duplicated := document.Document.Tasks[name]; duplicated {
+			return errors.Errorf("duplicated task: '%s' (previously defined at %s)", name, exist.Source)
+		}
+
+		instance := config
+		instance.Index = config.Index + 1 + i
+		instance.HeadingLevel = config.HeadingLevel + 1
+		instance.Title = name
+		instance.Call = name
+		instance.Skips = config.Skips.Clone()
+		instance.Envs = make(map[string]string, len(config.Envs)+len(keys))
+		for key, value := range config.Envs {
+			instance.Envs[key] = value
+		}
+		for j := range keys {
+			instance.Envs[keys[j]] = values[j]
+		}
+
+		base.DependTasks = append(base.DependTasks, name)
+		document.Document.Tasks[name] = instance
+	}
+
+	document.Document.Tasks[base.Call] = base
+	return nil
+}
+
+// Returns the cartesian product of the matrix values in the order of keys.
+func matrixCombinations(keys []string, matrix map[string][]string) [][]string {
+	combinations := [][]string{{}}
+	for _, key := range keys {
+		next := make([][]string, 0, len(combinations)*len(matrix[key]))
+		for _, combination := range combinations {
+			for _, value := range matrix[key] {
+				next = append(next, append(combination[:len(combination):len(combination)], value))
+			}
+		}
+		combinations = next
+	}
+
+	return combinations
+}
+
+// Returns the task name like 'test[go=1.21,tags=integration]'.
+func matrixTaskName(call string, keys []string, values []string) string {
+	pairs := make([]string, 0, len(keys))
+	for i := range keys {
+		pairs = append(pairs, keys[i]+"="+values[i])
+	}
+
+	return call + "[" + strings.Join(pairs, ",") + "]"
+}
+
+// Returns the resolve options of the interpreters in the order of languages.
+func (config ParseResultGlobalConfig) ResolveOptions() []resolver.ResolveOption {
+	langs := make([]string, 0, len(config.Interpreters))
+	for lang := range config.Interpreters {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+
+	options := make([]resolver.ResolveOption, 0, len(langs))
+	for _, lang := range langs {
+		interpreter := config.Interpreters[lang]
+		options = append(options, resolver.ResolveOption{
+			Lang:    []string{lang},
+			Command: interpreter.Command,
+			CmdOpt:  interpreter.Option,
+			Args:    interpreter.Args,
+			Arg0:    interpreter.Arg0,
+			Mode:    model.ExecMode(interpreter.Mode),
+			Ext:     interpreter.Ext,
+			Shebang: interpreter.Shebang,
+		})
+	}
+
+	return options
+}
+
 func NewDocFromMarkdownParsing(result ParseResult) model.NewDocumentOption {
 	return func(ctx context.Context, document *model.DocumentConfig) error {
-		document.Document.Title = result.Title
-		document.Document.Description = result.Description
-
-		// Update root directory.
-		// This parameter is optional.
-		if result.Config.Root != "" {
-			if filepath.IsAbs(result.Config.Root) {
-				document.Document.Rootdir = result.Config.Root
+		return newDocFromMarkdownParsing(ctx, document, result, []string{result.Filename})
+	}
+}
+
+func newDocFromMarkdownParsing(ctx context.Context, document *model.DocumentConfig, result ParseResult, includeChain []string) error {
+	document.Document.Title = result.Title
+	document.Document.Description = result.Description
+	configSource := model.SourcePosition{
+		Filename: result.Filename,
+		Line:     result.ConfigPosition.Line,
+		Column:   result.ConfigPosition.Column,
+	}
+
+	// Update root directory.
+	// This parameter is optional.
+	if result.Config.Root != "" {
+		if filepath.IsAbs(result.Config.Root) {
+			document.Document.Rootdir = result.Config.Root
+		} else {
+			document.Document.Rootdir = filepath.Join(document.Document.Rootdir, result.Config.Root)
+		}
+	}
+
+	// Override the interpreters with the ones defined in the document.
+	if err := resolver.NewDocumentWithPathResolver(result.Config.ResolveOptions()...)(ctx, document); err != nil {
+		return configSource.WrapError(err)
+	}
+
+	if result.Config.Hash != "" {
+		algorithm := model.HashAlgorithm(result.Config.Hash)
+		if err := algorithm.Validate(); err != nil {
+			return configSource.WrapError(err)
+		}
+		document.Document.HashAlgorithm = algorithm
+	}
+
+	// Read dotenv files.
+	for i := range result.Config.Environ.Dotenvs {
+		if !filepath.IsAbs(result.Config.Environ.Dotenvs[i]) {
+			result.Config.Environ.Dotenvs[i] = filepath.Join(document.Document.Rootdir, result.Config.Environ.Dotenvs[i])
+		}
+		err := environ.LoadDotenv(result.Config.Environ.Dotenvs[i], func(key, value string) {
+			document.Document.GlobalEnvs[key] = value
+		})
+		if err != nil {
+			if os.IsNotExist(err) {
+				docstak.GetLogger(ctx).Warn("dotenv file not found", slog.String("filename", result.Config.Environ.Dotenvs[i]))
 			} else {
-				filepath.Join(document.Document.Rootdir, result.Config.Root)
+				return configSource.WrapError(err)
 			}
 		}
+	}
 
-		// Read dotenv files.
-		for i := range result.Config.Environ.Dotenvs {
-			if !path.IsAbs(result.Config.Environ.Dotenvs[i]) {
-				result.Config.Environ.Dotenvs[i] = path.Join(document.Document.Rootdir, result.Config.Environ.Dotenvs[i])
-			}
-			err := environ.LoadDotenv(result.Config.Environ.Dotenvs[i], func(key, value string) {
-				document.Document.GlobalEnvs[key] = value
-			})
-			if err != nil {
-				if os.IsNotExist(err) {
-					docstak.GetLogger(ctx).Warn("dotenv file not found", slog.String("filename", result.Config.Environ.Dotenvs[i]))
-				} else {
-					return err
-				}
-			}
+	// Set environment variables.
+	// It's higher priority than dotenv files.
+	for key, value := range result.Config.Environ.Variables {
+		document.Document.GlobalEnvs[key] = value
+	}
+
+	for i := range result.Tasks {
+		if err := setDocumentTask(ctx, document, result.Filename, result.Tasks[i]); err != nil {
+			return err
 		}
+	}
 
-		// Set environment variables.
-		// It's higher priority than dotenv files.
-		for key, value := range result.Config.Environ.Variables {
-			document.Document.GlobalEnvs[key] = value
+	for i := range result.Config.Include {
+		if err := includeDocument(ctx, document, configSource, result.Config.Include[i], includeChain); err != nil {
+			return err
 		}
+	}
 
-		for i := range result.Tasks {
-			if err := setDocumentTask(ctx, document, result.Tasks[i]); err != nil {
-				return err
-			}
+	return nil
+}
+
+// Read the included markdown file and add its tasks with namespace prefix.
+func includeDocument(ctx context.Context, document *model.DocumentConfig, configSource model.SourcePosition, include ParseResultInclude, includeChain []string) error {
+	filename := configSource.Filename
+	includeFilename := include.Path
+	if !filepath.IsAbs(includeFilename) {
+		includeFilename = filepath.Join(filepath.Dir(filename), includeFilename)
+	}
+
+	for i := range includeChain {
+		if includeChain[i] == includeFilename {
+			return configSource.WrapError(errors.Errorf("include cycle detected: %s", strings.Join(append(includeChain, includeFilename), " -> ")))
 		}
+	}
+
+	prefix := include.Prefix
+	if prefix == "" {
+		prefix = filepath.Base(filepath.Dir(includeFilename))
+	}
 
-		return nil
+	po, err := FromFile(includeFilename)
+	if err != nil {
+		return configSource.WrapError(errors.WithMessage(err, "cannot include file"))
 	}
+
+	parsed, err := ParseMarkdown(ctx, po)
+	if err != nil {
+		return errors.WithMessagef(err, "cannot parse included file '%s'", includeFilename)
+	}
+
+	// Included document has own root directory and environment variables.
+	// Interpreters defined in the included document don't affect the including one.
+	included := &model.DocumentConfig{
+		ExecPathResolver: maps.Clone(document.ExecPathResolver),
+		Document: model.Document{
+			Rootdir:       filepath.Dir(includeFilename),
+			Tasks:         map[string]model.DocumentTask{},
+			GlobalEnvs:    map[string]string{},
+			HashAlgorithm: document.Document.HashAlgorithm,
+		},
+	}
+
+	if err := newDocFromMarkdownParsing(ctx, included, parsed, append(includeChain[:len(includeChain):len(includeChain)], includeFilename)); err != nil {
+		return err
+	}
+
+	offset := len(document.Document.Tasks)
+	for _, call := range included.Document.SortedCalls() {
+		task := included.Document.Tasks[call]
+		name := prefix + "/" + call
+
+		if exist, duplicated := document.Document.Tasks[name]; duplicated {
+			return task.Source.WrapError(errors.Errorf("duplicated task: '%s' (previously defined at %s)", name, exist.Source))
+		}
+
+		task.Call = name
+		task.Index += offset
+		dependTasks := make([]string, 0, len(task.DependTasks))
+		for i := range task.DependTasks {
+			dependTasks = append(dependTasks, prefix+"/"+task.DependTasks[i])
+		}
+		task.DependTasks = dependTasks
+
+		document.Document.Tasks[name] = task
+	}
+
+	return nil
 }
@@ -0,0 +1,365 @@
+/*
+Copyright 2024 Kasai Kou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package markdown
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kasaikou/markflow/docstak"
+	"github.com/kasaikou/markflow/docstak/model"
+	"github.com/kasaikou/markflow/docstak/srun"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorSourcePosition(t *testing.T) {
+	ctx := docstak.WithLogger(context.Background(), slog.New(slog.NewTextHandler(os.Stderr, nil)))
+
+	tests := []struct {
+		Name     string
+		Markdown string
+		Position string
+	}{
+		{
+			Name:     "duplicated",
+			Markdown: "# root\n\n## task\n\n```sh\necho 1\n```\n\n## task\n\n```sh\necho 2\n```\n",
+			Position: "docstak.md:9:4",
+		},
+		{
+			Name:     "unknown language",
+			Markdown: "# root\n\n## task\n\n```unknown-lang\necho 1\n```\n",
+			Position: "docstak.md:6:1",
+		},
+		{
+			Name:     "invalid yaml",
+			Markdown: "# root\n\n## task\n\n```yaml:docstak.yml\nprevious: {\n```\n",
+			Position: "docstak.md:6:1",
+		},
+		{
+			Name:     "circulated",
+			Markdown: "# root\n\n## a\n\n```yaml:docstak.yml\nprevious: [b]\n```\n\n## b\n\n```yaml:docstak.yml\nprevious: [a]\n```\n",
+			Position: "docstak.md:",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			dir := writeTestFiles(t, map[string]string{"docstak.md": test.Markdown})
+			_, err := newTestDocument(ctx, dir)
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), filepath.Join(dir, test.Position))
+			}
+		})
+	}
+}
+
+func TestMatrixTaskName(t *testing.T) {
+	keys := []string{"go", "tags"}
+	matrix := map[string][]string{
+		"go":   {"1.21", "1.22"},
+		"tags": {"integration", "unit"},
+	}
+
+	names := []string{}
+	for _, values := range matrixCombinations(keys, matrix) {
+		names = append(names, matrixTaskName("test", keys, values))
+	}
+
+	assert.Equal(t, []string{
+		"test[go=1.21,tags=integration]",
+		"test[go=1.21,tags=unit]",
+		"test[go=1.22,tags=integration]",
+		"test[go=1.22,tags=unit]",
+	}, names)
+}
+
+func TestMatrixTask(t *testing.T) {
+	ctx := docstak.WithLogger(context.Background(), slog.New(slog.NewTextHandler(os.Stderr, nil)))
+	dir := writeTestFiles(t, map[string]string{
+		"docstak.md": "# root\n\n" +
+			"## download\n\n```sh\necho download\n```\n\n" +
+			"## test\n\n```yaml:docstak.yml\nprevious: [download]\nmatrix:\n  tags: [unit, integration]\n  go: ['1.21', '1.22']\n```\n\n```sh\necho \"$go $tags\"\n```\n\n" +
+			"## ci\n\n```yaml:docstak.yml\nprevious: [test]\n```\n\n```sh\necho ci\n```\n",
+	})
+
+	document, err := newTestDocument(ctx, dir)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	instances := []string{
+		"test[go=1.21,tags=unit]",
+		"test[go=1.21,tags=integration]",
+		"test[go=1.22,tags=unit]",
+		"test[go=1.22,tags=integration]",
+	}
+	assert.Equal(t, instances, document.Tasks["test"].DependTasks)
+	assert.Empty(t, document.Tasks["test"].Scripts)
+	for _, instance := range instances {
+		assert.Equal(t, []string{"download"}, document.Tasks[instance].DependTasks)
+		assert.Len(t, document.Tasks[instance].Scripts, 1)
+	}
+	assert.Equal(t, map[string]string{"go": "1.22", "tags": "unit"}, document.Tasks["test[go=1.22,tags=unit]"].Envs)
+	assert.Equal(t, []string{"root", "download", "test"}, document.SortedCalls()[:3])
+	assert.Equal(t, "ci", document.SortedCalls()[7])
+
+	// The task depending on the matrix task waits for every instance.
+	mu := sync.Mutex{}
+	executed := []string{}
+	exit := docstak.ExecuteContext(ctx, document,
+		docstak.ExecuteOptCalls("ci"),
+		docstak.ExecuteOptProcessExec(func(ctx context.Context, task model.DocumentTask, runner *srun.ScriptRunner) (int, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			executed = append(executed, task.Call)
+			return 0, nil
+		}),
+	).ExitCode
+
+	assert.Equal(t, 0, exit)
+	assert.Len(t, executed, 6)
+	assert.Equal(t, "download", executed[0])
+	assert.ElementsMatch(t, instances, executed[1:5])
+	assert.Equal(t, "ci", executed[5])
+}
+
+func TestWorkdir(t *testing.T) {
+	ctx := docstak.WithLogger(context.Background(), slog.New(slog.NewTextHandler(os.Stderr, nil)))
+	dir := writeTestFiles(t, map[string]string{
+		"docstak.md": "```yaml:docstak.yml\nroot: project\n```\n\n" +
+			"# root\n\n" +
+			"## build\n\n```sh\nmake\n```\n\n" +
+			"## web\n\n```yaml:docstak.yml\nworkdir: web\nenviron:\n  dotenv: [.env]\n```\n\n```sh\nnpm run build\n```\n",
+		"project/web/.env": "NODE_ENV=production\n",
+	})
+
+	document, err := newTestDocument(ctx, dir)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, filepath.Join(dir, "project"), document.Rootdir)
+	build := document.Tasks["build"]
+	assert.Equal(t, filepath.Join(dir, "project"), build.WorkingDir())
+	web := document.Tasks["web"]
+	assert.Equal(t, filepath.Join(dir, "project", "web"), web.WorkingDir())
+	assert.Equal(t, "production", web.Envs["NODE_ENV"])
+}
+
+func TestInterpreters(t *testing.T) {
+	ctx := docstak.WithLogger(context.Background(), slog.New(slog.NewTextHandler(os.Stderr, nil)))
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash is not found")
+	}
+
+	dir := writeTestFiles(t, map[string]string{
+		"docstak.md": "```yaml:docstak.yml\ninterpreters:\n" +
+			"  sh:\n    command: bash\n    option: -c\n    arg0: true\n" +
+			"  awk:\n    command: awk\n    args: [-f, /dev/stdin]\n" +
+			"  missing:\n    command: docstak-missing-interpreter\n```\n\n" +
+			"# root\n\n" +
+			"## overridden\n\n```sh\necho $BASH_VERSION\n```\n\n" +
+			"## added\n\n```awk\nBEGIN { print \"hello\" }\n```\n\n" +
+			"## fence\n\n```sh:/bin/sh\necho hello\n```\n",
+	})
+
+	document, err := newTestDocument(ctx, dir)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, model.ExecConfig{ExecPath: bash, CmdOpt: "-c", Arg0: true}, document.Tasks["overridden"].Scripts[0].Config)
+	assert.Equal(t, []string{"-f", "/dev/stdin"}, document.Tasks["added"].Scripts[0].Config.Args)
+	assert.Equal(t, "/bin/sh", document.Tasks["fence"].Scripts[0].Config.ExecPath)
+	assert.Equal(t, "-c", document.Tasks["fence"].Scripts[0].Config.CmdOpt)
+}
+
+func TestInterpreterNotFound(t *testing.T) {
+	ctx := docstak.WithLogger(context.Background(), slog.New(slog.NewTextHandler(os.Stderr, nil)))
+
+	cases := map[string]string{
+		"overridden": "```yaml:docstak.yml\ninterpreters:\n  sh:\n    command: docstak-missing-interpreter\n```\n\n# root\n\n## task\n\n```sh\necho\n```\n",
+		"fence":      "# root\n\n## task\n\n```sh:/docstak/missing/sh\necho\n```\n",
+	}
+
+	for name, content := range cases {
+		t.Run(name, func(t *testing.T) {
+			dir := writeTestFiles(t, map[string]string{"docstak.md": content})
+			_, err := newTestDocument(ctx, dir)
+			assert.ErrorContains(t, err, filepath.Join(dir, "docstak.md")+":")
+		})
+	}
+}
+
+func TestInterpreterModes(t *testing.T) {
+	ctx := docstak.WithLogger(context.Background(), slog.New(slog.NewTextHandler(os.Stderr, nil)))
+	dir := writeTestFiles(t, map[string]string{
+		"docstak.md": "```yaml:docstak.yml\ninterpreters:\n" +
+			"  go:\n    command: sh\n    args: [-e]\n    mode: file\n" +
+			"  script:\n    command: sh\n    mode: file\n    ext: .sh\n    shebang: true\n" +
+			"  stdin:\n    command: sh\n    option: -s\n    mode: stdin\n```\n\n" +
+			"# root\n\n" +
+			"## go\n\n```go\necho\n```\n\n" +
+			"## script\n\n```script\necho\n```\n\n" +
+			"## stdin\n\n```stdin\necho\n```\n",
+	})
+
+	document, err := newTestDocument(ctx, dir)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	goConfig := document.Tasks["go"].Scripts[0].Config
+	assert.Equal(t, model.ExecModeFile, goConfig.Mode)
+	assert.Equal(t, ".go", goConfig.Ext)
+	assert.False(t, goConfig.Shebang)
+
+	scriptConfig := document.Tasks["script"].Scripts[0].Config
+	assert.Equal(t, ".sh", scriptConfig.Ext)
+	assert.True(t, scriptConfig.Shebang)
+
+	stdinConfig := document.Tasks["stdin"].Scripts[0].Config
+	assert.Equal(t, model.ExecModeStdin, stdinConfig.Mode)
+	assert.Equal(t, "-s", stdinConfig.CmdOpt)
+
+	dir = writeTestFiles(t, map[string]string{
+		"docstak.md": "```yaml:docstak.yml\ninterpreters:\n  sh:\n    command: sh\n    mode: tempfile\n```\n\n# root\n",
+	})
+	_, err = newTestDocument(ctx, dir)
+	assert.ErrorContains(t, err, "unknown exec mode 'tempfile'")
+}
+
+func TestGracePeriod(t *testing.T) {
+	ctx := docstak.WithLogger(context.Background(), slog.New(slog.NewTextHandler(os.Stderr, nil)))
+	dir := writeTestFiles(t, map[string]string{
+		"docstak.md": "# root\n\n## serve\n\n```yaml:docstak.yml\ngrace-period: 30s\n```\n\n```sh\nnpm start\n```\n",
+	})
+
+	document, err := newTestDocument(ctx, dir)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 30*time.Second, document.Tasks["serve"].GracePeriod)
+	}
+
+	dir = writeTestFiles(t, map[string]string{
+		"docstak.md": "# root\n\n## serve\n\n```yaml:docstak.yml\ngrace-period: soon\n```\n\n```sh\nnpm start\n```\n",
+	})
+	_, err = newTestDocument(ctx, dir)
+	assert.ErrorContains(t, err, filepath.Join(dir, "docstak.md")+":3:4: invalid grace-period")
+}
+
+func TestTimeout(t *testing.T) {
+	ctx := docstak.WithLogger(context.Background(), slog.New(slog.NewTextHandler(os.Stderr, nil)))
+	dir := writeTestFiles(t, map[string]string{
+		"docstak.md": "# root\n\n## test\n\n```yaml:docstak.yml\ntimeout: 10m\n```\n\n" +
+			"```sh\ngo test ./...\n```\n\n```sh timeout=30s\ngo vet ./...\n```\n",
+	})
+
+	document, err := newTestDocument(ctx, dir)
+	if assert.NoError(t, err) {
+		task := document.Tasks["test"]
+		assert.Equal(t, 10*time.Minute, task.Timeout)
+		assert.Equal(t, time.Duration(0), task.Scripts[0].Timeout)
+		assert.Equal(t, 30*time.Second, task.Scripts[1].Timeout)
+	}
+
+	dir = writeTestFiles(t, map[string]string{
+		"docstak.md": "# root\n\n## test\n\n```sh timeout=never\ngo test ./...\n```\n",
+	})
+	_, err = newTestDocument(ctx, dir)
+	assert.ErrorContains(t, err, filepath.Join(dir, "docstak.md")+":6:1: invalid timeout")
+}
+
+func TestRetry(t *testing.T) {
+	ctx := docstak.WithLogger(context.Background(), slog.New(slog.NewTextHandler(os.Stderr, nil)))
+	dir := writeTestFiles(t, map[string]string{
+		"docstak.md": "# root\n\n## test\n\n```yaml:docstak.yml\nretry:\n  attempts: 3\n  delay: 2s\n  backoff: 2\n  on-exit-codes: [1]\n```\n\n```sh\ngo test ./...\n```\n",
+	})
+
+	document, err := newTestDocument(ctx, dir)
+	if assert.NoError(t, err) {
+		assert.Equal(t, model.TaskRetry{Attempts: 3, Delay: 2 * time.Second, Backoff: 2, OnExitCodes: []int{1}}, document.Tasks["test"].Retry)
+	}
+
+	dir = writeTestFiles(t, map[string]string{
+		"docstak.md": "# root\n\n## test\n\n```yaml:docstak.yml\nretry:\n  attempts: 0\n```\n\n```sh\ngo test ./...\n```\n",
+	})
+	_, err = newTestDocument(ctx, dir)
+	assert.ErrorContains(t, err, "retry attempts must be positive")
+}
+
+func TestSkipsGenerates(t *testing.T) {
+	ctx := docstak.WithLogger(context.Background(), slog.New(slog.NewTextHandler(os.Stderr, nil)))
+	dir := writeTestFiles(t, map[string]string{
+		"docstak.md": "# root\n\n## build\n\n```yaml:docstak.yml\nskips:\n  file:\n    newer-than: ['**.go']\n  generates: [bin/app]\n```\n\n```sh\ngo build -o bin/app\n```\n",
+	})
+
+	document, err := newTestDocument(ctx, dir)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"**.go"}, document.Tasks["build"].Skips.NewerThanPaths)
+		assert.Equal(t, []string{"bin/app"}, document.Tasks["build"].Skips.GeneratePaths)
+	}
+
+	dir = writeTestFiles(t, map[string]string{
+		"docstak.md": "# root\n\n## build\n\n```yaml:docstak.yml\nskips:\n  file:\n    newer-than: ['**.go']\n```\n\n```sh\ngo build -o bin/app\n```\n",
+	})
+	_, err = newTestDocument(ctx, dir)
+	assert.ErrorContains(t, err, filepath.Join(dir, "docstak.md")+":3:4: skips.file.newer-than requires skips.generates")
+}
+
+func TestHashAlgorithm(t *testing.T) {
+	ctx := docstak.WithLogger(context.Background(), slog.New(slog.NewTextHandler(os.Stderr, nil)))
+	dir := writeTestFiles(t, map[string]string{
+		"docstak.md":     "```yaml:docstak.yml\nhash: sha256\ninclude: [sub/docstak.md]\n```\n\n# root\n\n## build\n\n```sh\ngo build\n```\n",
+		"sub/docstak.md": "# sub\n\n## test\n\n```sh\ngo test\n```\n",
+	})
+
+	document, err := newTestDocument(ctx, dir)
+	if assert.NoError(t, err) {
+		assert.Equal(t, model.HashSHA256, document.HashAlgorithm)
+		assert.Equal(t, model.HashSHA256, document.Tasks["sub/test"].Parent.HashAlgorithm)
+	}
+
+	dir = writeTestFiles(t, map[string]string{
+		"docstak.md": "```yaml:docstak.yml\nhash: xxhash\n```\n\n# root\n\n## build\n\n```sh\ngo build\n```\n",
+	})
+	_, err = newTestDocument(ctx, dir)
+	assert.ErrorContains(t, err, "unknown hash algorithm 'xxhash'")
+}
+
+func TestEnvConditions(t *testing.T) {
+	ctx := docstak.WithLogger(context.Background(), slog.New(slog.NewTextHandler(os.Stderr, nil)))
+	dir := writeTestFiles(t, map[string]string{
+		"docstak.md": "```yaml:docstak.yml\nenviron:\n  vars:\n    STAGE: dev\n```\n\n# root\n\n## deploy\n\n" +
+			"```yaml:docstak.yml\nrequires:\n  env:\n    set: [AWS_PROFILE]\n    equals:\n      STAGE: dev\nskips:\n  env:\n    equals:\n      CI: \"true\"\n```\n\n```sh\n./deploy.sh\n```\n",
+	})
+
+	document, err := newTestDocument(ctx, dir)
+	if assert.NoError(t, err) {
+		task := document.Tasks["deploy"]
+		assert.Equal(t, map[string]string{"STAGE": "dev"}, document.GlobalEnvs)
+		assert.Equal(t, model.TaskEnvCondition{Set: []string{"AWS_PROFILE"}, Equals: map[string]string{"STAGE": "dev"}}, task.Requires.Env)
+		assert.Equal(t, model.TaskEnvCondition{Equals: map[string]string{"CI": "true"}}, task.Skips.Env)
+		assert.Equal(t, "dev", task.Environ(nil)["STAGE"])
+	}
+}
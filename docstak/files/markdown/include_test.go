@@ -0,0 +1,118 @@
+/*
+Copyright 2024 Kasai Kou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package markdown
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kasaikou/markflow/docstak"
+	"github.com/kasaikou/markflow/docstak/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeTestFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		filename := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func newTestDocument(ctx context.Context, dir string) (model.Document, error) {
+	po, err := FromFile(filepath.Join(dir, "docstak.md"))
+	if err != nil {
+		return model.Document{}, err
+	}
+
+	parsed, err := ParseMarkdown(ctx, po)
+	if err != nil {
+		return model.Document{}, err
+	}
+
+	return model.NewDocument(ctx,
+		model.NewDocOptionRootDir(dir),
+		func(ctx context.Context, d *model.DocumentConfig) error {
+			d.ExecPathResolver["sh"] = model.ExecConfig{ExecPath: "/bin/sh", CmdOpt: "-c"}
+			return nil
+		},
+		NewDocFromMarkdownParsing(parsed),
+	)
+}
+
+func TestInclude(t *testing.T) {
+	ctx := docstak.WithLogger(context.Background(), slog.New(slog.NewTextHandler(os.Stderr, nil)))
+	dir := writeTestFiles(t, map[string]string{
+		"docstak.md": "```yaml:docstak.yml\ninclude:\n  - svc-a/docstak.md\n  - path: services/b.md\n    prefix: svc-b\n```\n\n" +
+			"# root\n\n" +
+			"## test\n\n```yaml:docstak.yml\nprevious: [svc-a/test, svc-b/test]\n```\n",
+		"svc-a/docstak.md": "# svc-a\n\n## download\n\n```sh\ngo mod download\n```\n\n" +
+			"## test\n\n```yaml:docstak.yml\nprevious: [download]\n```\n\n```sh\ngo test ./...\n```\n",
+		"services/b.md": "# svc-b\n\n## test\n\n```sh\ngo test ./...\n```\n",
+	})
+
+	document, err := newTestDocument(ctx, dir)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, []string{"svc-a/download"}, document.Tasks["svc-a/test"].DependTasks)
+	assert.Equal(t, []string{"svc-a/test", "svc-b/test"}, document.Tasks["test"].DependTasks)
+	assert.Equal(t, filepath.Join(dir, "svc-a"), document.Tasks["svc-a/test"].Parent.Rootdir)
+	assert.Equal(t, filepath.Join(dir, "services"), document.Tasks["svc-b/test"].Parent.Rootdir)
+	assert.Equal(t, filepath.Join(dir, "services", "b.md"), document.Tasks["svc-b/test"].Source.Filename)
+	assert.Equal(t, "svc-b/test", document.Tasks["svc-b/test"].Call)
+}
+
+func TestIncludeCycle(t *testing.T) {
+	ctx := docstak.WithLogger(context.Background(), slog.New(slog.NewTextHandler(os.Stderr, nil)))
+	dir := writeTestFiles(t, map[string]string{
+		"docstak.md":   "```yaml:docstak.yml\ninclude: [a/docstak.md]\n```\n",
+		"a/docstak.md": "```yaml:docstak.yml\ninclude: [../b/docstak.md]\n```\n",
+		"b/docstak.md": "```yaml:docstak.yml\ninclude: [../a/docstak.md]\n```\n",
+	})
+
+	_, err := newTestDocument(ctx, dir)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), filepath.Join(dir, "a", "docstak.md"))
+		assert.Contains(t, err.Error(), filepath.Join(dir, "b", "docstak.md"))
+	}
+}
+
+func TestIncludeDuplicated(t *testing.T) {
+	ctx := docstak.WithLogger(context.Background(), slog.New(slog.NewTextHandler(os.Stderr, nil)))
+	dir := writeTestFiles(t, map[string]string{
+		"docstak.md":     "```yaml:docstak.yml\ninclude: [svc/docstak.md]\n```\n\n# root\n\n## svc/test\n\n```sh\necho root\n```\n",
+		"svc/docstak.md": "# svc\n\n## test\n\n```sh\necho svc\n```\n",
+	})
+
+	_, err := newTestDocument(ctx, dir)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), filepath.Join(dir, "docstak.md"))
+		assert.Contains(t, err.Error(), filepath.Join(dir, "svc", "docstak.md"))
+	}
+}
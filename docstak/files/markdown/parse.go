@@ -17,10 +17,12 @@ limitations under the License.
 package markdown
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"os"
 	"regexp"
+	"strings"
 	"unsafe"
 
 	"github.com/cockroachdb/errors"
@@ -37,19 +39,67 @@ type FileEnvironment struct {
 }
 
 type ParseResult struct {
-	Title       string                  `json:"title"`
-	Description string                  `json:"description"`
-	Tasks       []ParseResultTask       `json:"tasks,omitempty"`
-	Config      ParseResultGlobalConfig `json:"config,omitempty"`
+	Filename       string                  `json:"-"`
+	Title          string                  `json:"title"`
+	Description    string                  `json:"description"`
+	Tasks          []ParseResultTask       `json:"tasks,omitempty"`
+	Config         ParseResultGlobalConfig `json:"config,omitempty"`
+	ConfigPosition ParsePosition           `json:"-"` // Position of the global config block.
 }
 
 type ParseResultGlobalConfig struct {
 	Root    string                    `json:"root" yaml:"root"`
 	Environ ParseResultTaskConfigEnvs `json:"environ" yaml:"environ"`
+	Include []ParseResultInclude      `json:"include,omitempty" yaml:"include"`
+	// Interpreters for each language of code blocks, which override the built-in ones.
+	Interpreters map[string]ParseResultInterpreter `json:"interpreters,omitempty" yaml:"interpreters"`
+	// Algorithm to hash the files watched by skips.file.not-changed, 'md5' (default) or 'sha256'.
+	Hash string `json:"hash,omitempty" yaml:"hash"`
+}
+
+type ParseResultInterpreter struct {
+	Command string   `json:"command" yaml:"command"`
+	Option  string   `json:"option,omitempty" yaml:"option"`
+	Args    []string `json:"args,omitempty" yaml:"args"`
+	Arg0    bool     `json:"arg0,omitempty" yaml:"arg0"`
+	Mode    string   `json:"mode,omitempty" yaml:"mode"` // One of 'argument' (default), 'stdin' and 'file'.
+	Ext     string   `json:"ext,omitempty" yaml:"ext"`
+	Shebang bool     `json:"shebang,omitempty" yaml:"shebang"`
+}
+
+type ParseResultInclude struct {
+	Path   string `json:"path" yaml:"path"`
+	Prefix string `json:"prefix,omitempty" yaml:"prefix"`
+}
+
+// Accepts both of a path string and a mapping with path and prefix.
+func (pri *ParseResultInclude) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		pri.Prefix = ""
+		return node.Decode(&pri.Path)
+	}
+
+	type plain ParseResultInclude
+	return node.Decode((*plain)(pri))
+}
+
+// Line and column (1-based, in bytes) in the markdown file.
+type ParsePosition struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+func positionAt(source []byte, offset int) ParsePosition {
+	lineHead := bytes.LastIndexByte(source[:offset], '\n') + 1
+	return ParsePosition{
+		Line:   bytes.Count(source[:offset], []byte{'\n'}) + 1,
+		Column: offset - lineHead + 1,
+	}
 }
 
 type ParseResultTask struct {
 	Title        string                `json:"title"`
+	Position     ParsePosition         `json:"position"`
 	HeadingLevel int                   `json:"heading-level"`
 	Description  string                `json:"description"`
 	Config       ParseResultTaskConfig `json:"config,omitempty"`
@@ -61,6 +111,29 @@ type ParseResultTaskConfig struct {
 	Requires ParseResultTaskConfigRequires `json:"requires,omitempty" yaml:"requires"`
 	Skips    ParseResultTaskConfigSkips    `json:"skips,omitempty" yaml:"skips"`
 	Previous []string                      `json:"previous,omitempty" yaml:"previous"`
+	Parallel bool                          `json:"parallel,omitempty" yaml:"parallel"`
+	Params   []ParseResultTaskConfigParam  `json:"params,omitempty" yaml:"params"`
+	Matrix   map[string][]string           `json:"matrix,omitempty" yaml:"matrix"`
+	Workdir  string                        `json:"workdir,omitempty" yaml:"workdir"`
+	// Time to wait for the scripts to exit after each signal on cancellation like '30s'.
+	GracePeriod string `json:"grace-period,omitempty" yaml:"grace-period"`
+	// Time limit of each code block like '10m'.
+	Timeout string                      `json:"timeout,omitempty" yaml:"timeout"`
+	Retry   *ParseResultTaskConfigRetry `json:"retry,omitempty" yaml:"retry"`
+}
+
+type ParseResultTaskConfigRetry struct {
+	Attempts    int     `json:"attempts" yaml:"attempts"`
+	Delay       string  `json:"delay,omitempty" yaml:"delay"`
+	Backoff     float64 `json:"backoff,omitempty" yaml:"backoff"`
+	OnExitCodes []int   `json:"on-exit-codes,omitempty" yaml:"on-exit-codes"`
+}
+
+type ParseResultTaskConfigParam struct {
+	Name     string   `json:"name" yaml:"name"`
+	Default  string   `json:"default,omitempty" yaml:"default"`
+	Required bool     `json:"required,omitempty" yaml:"required"`
+	Allowed  []string `json:"allowed,omitempty" yaml:"allowed"`
 }
 
 type ParseResultTaskConfigEnvs struct {
@@ -70,20 +143,37 @@ type ParseResultTaskConfigEnvs struct {
 
 type ParseResultTaskConfigSkips struct {
 	File ParseResultTaskConfigFiles `json:"file,omitempty" yaml:"file"`
+	Env  ParseResultTaskConfigEnv   `json:"env,omitempty" yaml:"env"`
+	// Output files of the task, which must exist to skip the task.
+	Generates []string `json:"generates,omitempty" yaml:"generates"`
+	// Run the task again when the interpreter binaries are updated.
+	ExecVersion bool `json:"exec-version,omitempty" yaml:"exec-version"`
 }
 
 type ParseResultTaskConfigRequires struct {
 	File ParseResultTaskConfigFiles `json:"file,omitempty" yaml:"file"`
+	Env  ParseResultTaskConfigEnv   `json:"env,omitempty" yaml:"env"`
+}
+
+// Conditions of the environment variables merged from the document, dotenv files and the process.
+type ParseResultTaskConfigEnv struct {
+	Set    []string          `json:"set,omitempty" yaml:"set"`
+	Equals map[string]string `json:"equals,omitempty" yaml:"equals"`
 }
 
 type ParseResultTaskConfigFiles struct {
 	Exists      []string `json:"exist,omitempty" yaml:"exist"`
 	NotChangeds []string `json:"not-changed,omitempty" yaml:"not-changed"`
+	// Input files which must be older than the generated files.
+	NewerThans []string `json:"newer-than,omitempty" yaml:"newer-than"`
 }
 
 type ParseResultCommand struct {
-	Lang string `json:"lang"`
-	Code string `json:"code"`
+	Lang        string        `json:"lang"`
+	Interpreter string        `json:"interpreter,omitempty"` // Set with the info string like 'sh:/bin/bash'.
+	Timeout     string        `json:"timeout,omitempty"`     // Set with the info string like 'sh timeout=30s'.
+	Code        string        `json:"code"`
+	Position    ParsePosition `json:"position"`
 }
 
 var (
@@ -111,8 +201,17 @@ func FromLocalFile(workingDir, searchname string) (MarkdownOption, error) {
 	return MarkdownOption{filename: filename, bytes: b}, nil
 }
 
+func FromFile(filename string) (MarkdownOption, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return MarkdownOption{}, errors.WithMessagef(err, "cannot read file '%s'", filename)
+	}
+
+	return MarkdownOption{filename: filename, bytes: b}, nil
+}
+
 func ParseMarkdown(ctx context.Context, markdown MarkdownOption) (ParseResult, error) {
-	result := ParseResult{}
+	result := ParseResult{Filename: markdown.filename}
 	node := goldmark.DefaultParser().Parse(text.NewReader(markdown.bytes))
 
 	if node.Kind() != ast.KindDocument {
@@ -131,8 +230,13 @@ func ParseMarkdown(ctx context.Context, markdown MarkdownOption) (ParseResult, e
 			if baseHeading < 0 {
 				baseHeading = node.Level - 1
 			}
+			position := ParsePosition{}
+			if lines := node.Lines(); lines.Len() > 0 {
+				position = positionAt(markdown.bytes, lines.At(0).Start)
+			}
 			result.Tasks = append(result.Tasks, ParseResultTask{
 				Title:        titleStr,
+				Position:     position,
 				HeadingLevel: node.Level,
 			})
 			selected = &result.Tasks[len(result.Tasks)-1]
@@ -174,22 +278,51 @@ func ParseMarkdown(ctx context.Context, markdown MarkdownOption) (ParseResult, e
 			lang := node.Language(markdown.bytes)
 			langStr := unsafe.String(unsafe.SliceData(lang), len(lang))
 
+			// Position of the code (the info string if the code is empty).
+			position := ParsePosition{}
+			if lines := node.Lines(); lines.Len() > 0 {
+				position = positionAt(markdown.bytes, lines.At(0).Start)
+			} else if node.Info != nil {
+				position = positionAt(markdown.bytes, node.Info.Segment.Start)
+			}
+			errorAt := func(err error, msg string) error {
+				position := position
+				if unknownKey := (*UnknownKeyError)(nil); errors.As(err, &unknownKey) {
+					position = unknownKey.Position
+				}
+				return errors.WithMessagef(err, "%s:%d:%d: %s", markdown.filename, position.Line, position.Column, msg)
+			}
+
 			if selected == nil {
 				if yamlConfigRule.Match(lang) {
-					if err := yaml.Unmarshal(code, &result.Config); err != nil {
-						return result, errors.WithMessage(err, "failed to parse yaml format global config")
+					result.ConfigPosition = position
+					if err := decodeConfigStrict(code, &result.Config, position); err != nil {
+						return result, errorAt(err, "failed to parse yaml format global config")
 					}
 				}
 			} else { // selected != nil
 				if yamlConfigRule.Match(lang) {
-					if err := yaml.Unmarshal(code, &selected.Config); err != nil {
-						return result, err
+					if err := decodeConfigStrict(code, &selected.Config, position); err != nil {
+						return result, errorAt(err, fmt.Sprintf("failed to parse yaml format config of task '%s'", selected.Title))
 					}
 				} else { // yamlConfigRule.Match(lang) == false
-					selected.Commands = append(selected.Commands, ParseResultCommand{
-						Lang: langStr,
-						Code: codeStr,
-					})
+					langStr, interpreter, _ := strings.Cut(langStr, ":")
+					command := ParseResultCommand{
+						Lang:        langStr,
+						Interpreter: interpreter,
+						Code:        codeStr,
+						Position:    position,
+					}
+
+					// Attributes like 'timeout=30s' follow the language in the info string.
+					if node.Info != nil {
+						for _, attr := range strings.Fields(string(node.Info.Segment.Value(markdown.bytes)))[1:] {
+							if key, value, _ := strings.Cut(attr, "="); key == "timeout" {
+								command.Timeout = value
+							}
+						}
+					}
+					selected.Commands = append(selected.Commands, command)
 				}
 			}
 		}
@@ -48,19 +48,23 @@ func TestParseSample(t *testing.T) {
 		Tasks: []ParseResultTask{
 			{
 				Title:        "Scripts for github.com/kasaikou/markflow developpers",
+				Position:     ParsePosition{Line: 1, Column: 3},
 				HeadingLevel: 1,
 			},
 			{
 				Title:        "hello_world",
+				Position:     ParsePosition{Line: 3, Column: 4},
 				HeadingLevel: 2,
 				Description:  "Echo \"Hello World\"",
 				Commands: []ParseResultCommand{{
-					Lang: "sh",
-					Code: "echo \"Hello World, docstak!\"\n",
+					Lang:     "sh",
+					Code:     "echo \"Hello World, docstak!\"\n",
+					Position: ParsePosition{Line: 8, Column: 1},
 				}},
 			},
 			{
 				Title:        "download",
+				Position:     ParsePosition{Line: 11, Column: 4},
 				HeadingLevel: 2,
 				Description:  "Download dependencies",
 				Config: ParseResultTaskConfig{
@@ -76,33 +80,39 @@ func TestParseSample(t *testing.T) {
 					},
 				},
 				Commands: []ParseResultCommand{{
-					Lang: "sh",
-					Code: "go mod download\n",
+					Lang:     "sh",
+					Code:     "go mod download\n",
+					Position: ParsePosition{Line: 25, Column: 1},
 				}},
 			},
 			{
 				Title:        "test",
+				Position:     ParsePosition{Line: 28, Column: 4},
 				HeadingLevel: 2,
 				Description:  "Run go test",
 				Config: ParseResultTaskConfig{
 					Previous: []string{"download"},
 				},
 				Commands: []ParseResultCommand{{
-					Lang: "sh",
-					Code: "DOCSTAK_TEST_WORKSPACE_DIR=$(pwd) go test ./...\n",
+					Lang:     "sh",
+					Code:     "DOCSTAK_TEST_WORKSPACE_DIR=$(pwd) go test ./...\n",
+					Position: ParsePosition{Line: 37, Column: 1},
 				}},
 			},
 			{
 				Title:        "fmt",
+				Position:     ParsePosition{Line: 40, Column: 4},
 				HeadingLevel: 2,
 				Description:  "Format source codes",
 				Commands: []ParseResultCommand{{
-					Lang: "sh",
-					Code: "go fmt ./...\n",
+					Lang:     "sh",
+					Code:     "go fmt ./...\n",
+					Position: ParsePosition{Line: 45, Column: 1},
 				}},
 			},
 			{
 				Title:        "ci",
+				Position:     ParsePosition{Line: 48, Column: 4},
 				HeadingLevel: 2,
 				Description:  "Running on GitHub Actions, local, and so on.",
 				Config: ParseResultTaskConfig{
@@ -111,6 +121,7 @@ func TestParseSample(t *testing.T) {
 			},
 			{
 				Title:        "ci/depends",
+				Position:     ParsePosition{Line: 56, Column: 5},
 				HeadingLevel: 3,
 				Config: ParseResultTaskConfig{
 					Skips: ParseResultTaskConfigSkips{
@@ -120,20 +131,24 @@ func TestParseSample(t *testing.T) {
 					},
 				},
 				Commands: []ParseResultCommand{{
-					Lang: "sh",
-					Code: "go mod tidy &&\ngit diff --no-patch --exit-code go.sum\n",
+					Lang:     "sh",
+					Code:     "go mod tidy &&\ngit diff --no-patch --exit-code go.sum\n",
+					Position: ParsePosition{Line: 65, Column: 1},
 				}},
 			},
 			{
 				Title:        "ci/fmt",
+				Position:     ParsePosition{Line: 69, Column: 5},
 				HeadingLevel: 3,
 				Commands: []ParseResultCommand{{
-					Lang: "sh",
-					Code: "gofmt -l .\n",
+					Lang:     "sh",
+					Code:     "gofmt -l .\n",
+					Position: ParsePosition{Line: 72, Column: 1},
 				}},
 			},
 			{
 				Title:        "ci/coverage-test",
+				Position:     ParsePosition{Line: 75, Column: 5},
 				HeadingLevel: 3,
 				Config: ParseResultTaskConfig{
 					Previous: []string{"ci/coverage-test/go", "download"},
@@ -141,6 +156,7 @@ func TestParseSample(t *testing.T) {
 			},
 			{
 				Title:        "ci/coverage-test/go",
+				Position:     ParsePosition{Line: 81, Column: 6},
 				HeadingLevel: 4,
 				Config: ParseResultTaskConfig{
 					Skips: ParseResultTaskConfigSkips{
@@ -150,8 +166,9 @@ func TestParseSample(t *testing.T) {
 					},
 				},
 				Commands: []ParseResultCommand{{
-					Lang: "sh",
-					Code: "go test -coverprofile=coverage.txt -covermode=atomic ./...\n",
+					Lang:     "sh",
+					Code:     "go test -coverprofile=coverage.txt -covermode=atomic ./...\n",
+					Position: ParsePosition{Line: 90, Column: 1},
 				}},
 			},
 		},
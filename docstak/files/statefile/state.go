@@ -28,17 +28,27 @@ import (
 	"github.com/kasaikou/markflow/docstak/model"
 )
 
+// Version of the state file format.
+// The format without version has only the digest of the whole files in each rule.
+const Version = 2
+
 type State struct {
-	Tasks map[string]StateTask `json:"tasks"`
+	Version int                  `json:"version"`
+	Tasks   map[string]StateTask `json:"tasks"`
 }
 
 type StateTask struct {
 	Files map[string]StateTaskFile `json:"files,omitempty"`
+	// Fingerprint of the task definition when the task ran last time.
+	Definition string `json:"definition,omitempty"`
 }
 
 type StateTaskFile struct {
-	Rule StateTaskFileRule `json:"rule"`
-	MD5  string            `json:"md5,omitempty"`
+	Rule     StateTaskFileRule  `json:"rule"`
+	MD5      string             `json:"md5,omitempty"` // Only in the format without version.
+	Manifest model.FileManifest `json:"manifest"`
+	// Algorithm of the hashes in the manifest, which is md5 when omitted.
+	Algorithm model.HashAlgorithm `json:"algorithm,omitempty"`
 }
 
 type StateTaskFileRule struct {
@@ -64,6 +74,13 @@ func FromLocalFile(filename string) (State, error) {
 		return state, errors.WithMessage(err, "cannot load as toml file")
 	}
 
+	if state.Version > Version {
+		return State{}, errors.Errorf("state file is created with newer format (version: %d, supported: %d)", state.Version, Version)
+	}
+
+	// Files in the previous format keep their digests, which are replaced with the manifests after the tasks run.
+	state.Version = Version
+
 	return state, nil
 }
 
@@ -91,10 +108,13 @@ func SetStateParsed(result State) model.NewDocumentOption {
 			config, exist := d.Document.Tasks[call]
 
 			if exist {
+				config.Skips.Definition = taskStates.Definition
 				for _, file := range taskStates.Files {
 					for j := range config.Skips.NotChangedPaths {
 						if config.Skips.NotChangedPaths[j].IsEqualRule(file.Rule.Paths, file.Rule.Ignores) {
 							config.Skips.NotChangedPaths[j].MD5 = file.MD5
+							config.Skips.NotChangedPaths[j].Manifest = file.Manifest
+							config.Skips.NotChangedPaths[j].Algorithm = file.Algorithm
 						}
 					}
 				}
@@ -108,14 +128,19 @@ func SetStateParsed(result State) model.NewDocumentOption {
 
 func FromDocument(ctx context.Context, d model.Document) *State {
 	state := State{
-		Tasks: make(map[string]StateTask),
+		Version: Version,
+		Tasks:   make(map[string]StateTask),
 	}
 
 	empty := true
 
 	for call, task := range d.Tasks {
 		stateTask := StateTask{
-			Files: make(map[string]StateTaskFile),
+			Files:      make(map[string]StateTaskFile),
+			Definition: task.Skips.Definition,
+		}
+		if stateTask.Definition != "" {
+			empty = false
 		}
 
 		for i := range task.Skips.NotChangedPaths {
@@ -142,10 +167,12 @@ func FromDocument(ctx context.Context, d model.Document) *State {
 			json.NewEncoder(hash).Encode(rule)
 			key := hex.EncodeToString(hash.Sum(nil))
 
-			if task.Skips.NotChangedPaths[i].MD5 != "" {
+			if task.Skips.NotChangedPaths[i].Manifest != nil || task.Skips.NotChangedPaths[i].MD5 != "" {
 				stateTask.Files[key] = StateTaskFile{
-					Rule: rule,
-					MD5:  task.Skips.NotChangedPaths[i].MD5,
+					Rule:      rule,
+					MD5:       task.Skips.NotChangedPaths[i].MD5,
+					Manifest:  task.Skips.NotChangedPaths[i].Manifest,
+					Algorithm: task.Skips.NotChangedPaths[i].Algorithm,
 				}
 			}
 		}
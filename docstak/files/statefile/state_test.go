@@ -0,0 +1,103 @@
+/*
+Copyright 2024 Kasai Kou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statefile
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kasaikou/markflow/docstak/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestDocumentConfig() *model.DocumentConfig {
+	return &model.DocumentConfig{
+		Document: model.Document{
+			Tasks: map[string]model.DocumentTask{
+				"build": {
+					Call: "build",
+					Skips: model.TaskSkipCondition{
+						NotChangedPaths: []model.TaskFileNotChangedCondition{
+							{Paths: map[string]struct{}{"**.go": {}, "go.mod": {}}},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestMigrateState(t *testing.T) {
+	ctx := context.Background()
+	filename := filepath.Join(t.TempDir(), ".docstak_state.json")
+	legacy := `{"tasks": {"build": {"files": {"key": {"rule": {"paths": ["**.go", "go.mod"], "ignores": []}, "md5": "0123"}}}}}`
+	if err := os.WriteFile(filename, []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	state, err := FromLocalFile(filename)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, Version, state.Version)
+
+	config := newTestDocumentConfig()
+	if !assert.NoError(t, SetStateParsed(state)(ctx, config)) {
+		return
+	}
+	cond := config.Document.Tasks["build"].Skips.NotChangedPaths[0]
+	assert.Equal(t, "0123", cond.MD5)
+	assert.Nil(t, cond.Manifest)
+
+	// The manifest replaces the digest after the task runs.
+	manifest := model.FileManifest{{Path: "main.go", Size: 12, ModTime: time.Unix(1700000000, 0).UTC(), Hash: "4567"}}
+	task := config.Document.Tasks["build"]
+	task.Skips.NotChangedPaths[0].MD5 = ""
+	task.Skips.NotChangedPaths[0].Manifest = manifest
+	task.Skips.Definition = "89ab"
+	config.Document.Tasks["build"] = task
+
+	if !assert.NoError(t, SaveLocalFile(filename, *FromDocument(ctx, config.Document))) {
+		return
+	}
+
+	state, err = FromLocalFile(filename)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	config = newTestDocumentConfig()
+	if assert.NoError(t, SetStateParsed(state)(ctx, config)) {
+		cond := config.Document.Tasks["build"].Skips.NotChangedPaths[0]
+		assert.Empty(t, cond.MD5)
+		assert.Equal(t, manifest, cond.Manifest)
+		assert.Equal(t, "89ab", config.Document.Tasks["build"].Skips.Definition)
+	}
+}
+
+func TestNewerState(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), ".docstak_state.json")
+	if err := os.WriteFile(filename, []byte(`{"version": 100, "tasks": {}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := FromLocalFile(filename)
+	assert.ErrorContains(t, err, "newer format")
+}
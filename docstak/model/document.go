@@ -19,8 +19,13 @@ package model
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"maps"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/cockroachdb/errors"
 )
@@ -31,6 +36,8 @@ type Document struct {
 	Rootdir     string                  `json:"rootdir"`
 	Tasks       map[string]DocumentTask `json:"tasks,omitempty"`
 	GlobalEnvs  map[string]string       `json:"global_envs,omitempty"`
+	// Algorithm to hash the files watched by the skip conditions.
+	HashAlgorithm HashAlgorithm `json:"hash_algorithm,omitempty"`
 }
 
 type DocumentConfig struct {
@@ -42,6 +49,33 @@ type ExecConfig struct {
 	ExecPath string   `json:"exec_path"`
 	CmdOpt   string   `json:"cmd_opt,omitempty"`
 	Args     []string `json:"args,omitempty"`
+	// The first argument after the script is used as the script name ($0 of 'sh -c'),
+	// so that positional arguments start from the second one.
+	Arg0 bool `json:"arg0,omitempty"`
+	// How the script is passed to the interpreter.
+	Mode ExecMode `json:"mode,omitempty"`
+	// Extension of the temporary script file in ExecModeFile.
+	Ext string `json:"ext,omitempty"`
+	// Write the shebang line of ExecPath to the temporary script file in ExecModeFile.
+	Shebang bool `json:"shebang,omitempty"`
+}
+
+type ExecMode string
+
+const (
+	ExecModeArgument ExecMode = "argument" // Pass the script following CmdOpt and as stdin.
+	ExecModeStdin    ExecMode = "stdin"    // Pass the script only as stdin.
+	ExecModeFile     ExecMode = "file"     // Pass the filename of the temporary file the script is written to.
+)
+
+// Returns the error if the mode is unknown. Empty mode means ExecModeArgument.
+func (mode ExecMode) Validate() error {
+	switch mode {
+	case "", ExecModeArgument, ExecModeStdin, ExecModeFile:
+		return nil
+	default:
+		return errors.Errorf("unknown exec mode '%s' (must be one of '%s', '%s' or '%s')", mode, ExecModeArgument, ExecModeStdin, ExecModeFile)
+	}
 }
 
 type Condition interface {
@@ -49,32 +83,182 @@ type Condition interface {
 }
 
 type DocumentTask struct {
-	Parent      *Document            `json:"-"`
-	Title       string               `json:"omitempty"`
-	Call        string               `json:"call"`
-	Description string               `json:"description,omitempty"`
-	Scripts     []DocumentTaskScript `json:"scripts"`
-	Envs        map[string]string    `json:"envs,omitempty"`
-	Skips       TaskSkipCondition    `json:"skips,omitempty"`
-	Requires    TaskRequireCondition `json:"requires,omitempty"`
-	DependTasks []string             `json:"depend_tasks,omitempty"`
+	Parent       *Document            `json:"-"`
+	Index        int                  `json:"-"` // Position in the document.
+	Source       SourcePosition       `json:"source,omitempty"`
+	HeadingLevel int                  `json:"heading_level,omitempty"`
+	Title        string               `json:"omitempty"`
+	Call         string               `json:"call"`
+	Description  string               `json:"description,omitempty"`
+	Scripts      []DocumentTaskScript `json:"scripts"`
+	Envs         map[string]string    `json:"envs,omitempty"`
+	Skips        TaskSkipCondition    `json:"skips,omitempty"`
+	Requires     TaskRequireCondition `json:"requires,omitempty"`
+	DependTasks  []string             `json:"depend_tasks,omitempty"`
+	Parallel     bool                 `json:"parallel,omitempty"`
+	Params       []TaskParam          `json:"params,omitempty"`
+	Workdir      string               `json:"workdir,omitempty"` // Absolute path where the scripts run.
+	GracePeriod  time.Duration        `json:"grace_period,omitempty"`
+	Timeout      time.Duration        `json:"timeout,omitempty"` // Time limit of each code block.
+	Retry        TaskRetry            `json:"retry,omitempty"`
+}
+
+// Retries the failed code block of the task.
+type TaskRetry struct {
+	Attempts    int           `json:"attempts,omitempty"` // Maximum number of runs including the first one.
+	Delay       time.Duration `json:"delay,omitempty"`    // Wait before the first retry.
+	Backoff     float64       `json:"backoff,omitempty"`  // Multiplier of the delay for each retry.
+	OnExitCodes []int         `json:"on_exit_codes,omitempty"`
+}
+
+// Returns whether the failure with the exit code is retried.
+// Every failure is retried when OnExitCodes is empty.
+func (retry TaskRetry) IsRetryable(exit int) bool {
+	if exit == 0 {
+		return false
+	}
+	if len(retry.OnExitCodes) == 0 {
+		return true
+	}
+
+	return slices.Contains(retry.OnExitCodes, exit)
+}
+
+// Returns the directory where the scripts of the task run.
+// It falls back to the document root directory when the task has no working directory.
+func (dt *DocumentTask) WorkingDir() string {
+	if dt.Workdir != "" {
+		return dt.Workdir
+	}
+	if dt.Parent != nil {
+		return dt.Parent.Rootdir
+	}
+
+	return ""
+}
+
+// Returns the environment variables which the scripts of the task see.
+// The ones of the document, the task and environ (like os.Environ()) are merged in ascending order of priority.
+func (dt *DocumentTask) Environ(environ []string) map[string]string {
+	merged := map[string]string{}
+	if dt.Parent != nil {
+		for key, value := range dt.Parent.GlobalEnvs {
+			merged[key] = value
+		}
+	}
+
+	for key, value := range dt.Envs {
+		merged[key] = value
+	}
+
+	for i := range environ {
+		if key, value, found := strings.Cut(environ[i], "="); found {
+			merged[key] = value
+		}
+	}
+
+	return merged
+}
+
+type TaskParam struct {
+	Name     string   `json:"name"`
+	Default  string   `json:"default,omitempty"`
+	Required bool     `json:"required,omitempty"`
+	Allowed  []string `json:"allowed,omitempty"`
+}
+
+// Returns task names in the order they are defined in the document.
+func (d *Document) SortedCalls() []string {
+	calls := make([]string, 0, len(d.Tasks))
+	for call := range d.Tasks {
+		calls = append(calls, call)
+	}
+
+	sort.Slice(calls, func(i, j int) bool {
+		ti, tj := d.Tasks[calls[i]], d.Tasks[calls[j]]
+		if ti.Index != tj.Index {
+			return ti.Index < tj.Index
+		}
+		return calls[i] < calls[j]
+	})
+
+	return calls
+}
+
+// Position in the source file where the task is defined.
+type SourcePosition struct {
+	Filename string `json:"filename,omitempty"`
+	Line     int    `json:"line,omitempty"`
+	Column   int    `json:"column,omitempty"`
+}
+
+func (p SourcePosition) IsZero() bool { return p == SourcePosition{} }
+
+func (p SourcePosition) String() string {
+	if p.Line == 0 {
+		return p.Filename
+	}
+
+	return fmt.Sprintf("%s:%d:%d", p.Filename, p.Line, p.Column)
+}
+
+// Prefixes the error message with the position like "file:line:col: message".
+// Returns err itself when the position is unknown.
+func (p SourcePosition) WrapError(err error) error {
+	if err == nil || p.IsZero() {
+		return err
+	}
+
+	return errors.Wrapf(err, "%s", p.String())
 }
 
 type TaskSkipCondition struct {
 	ExistPaths      []string                      `json:"exist_paths,omitempty"`
 	NotChangedPaths []TaskFileNotChangedCondition `json:"not_changed_paths,omitempty"`
+	NewerThanPaths  []string                      `json:"newer_than_paths,omitempty"` // Inputs compared with GeneratePaths by mtime.
+	GeneratePaths   []string                      `json:"generate_paths,omitempty"`   // Outputs which must exist to skip.
+	Env             TaskEnvCondition              `json:"env,omitempty"`
+	// Include the interpreter binaries in the fingerprint of the task definition.
+	ExecVersion bool `json:"exec_version,omitempty"`
+	// Fingerprint of the task definition when the task ran last time.
+	Definition string `json:"definition,omitempty"`
+}
+
+// Returns the copy which does not share the state of rules.
+func (c TaskSkipCondition) Clone() TaskSkipCondition {
+	c.ExistPaths = append([]string(nil), c.ExistPaths...)
+	c.NewerThanPaths = append([]string(nil), c.NewerThanPaths...)
+	c.GeneratePaths = append([]string(nil), c.GeneratePaths...)
+	c.Env = c.Env.Clone()
+	c.NotChangedPaths = append([]TaskFileNotChangedCondition(nil), c.NotChangedPaths...)
+	return c
 }
 
 type TaskRequireCondition struct {
-	ExistPaths []string `json:"exist_paths,omitempty"`
+	ExistPaths []string         `json:"exist_paths,omitempty"`
+	Env        TaskEnvCondition `json:"env,omitempty"`
+}
+
+type TaskEnvCondition struct {
+	Set    []string          `json:"set,omitempty"`    // Variables which must be set, even if empty.
+	Equals map[string]string `json:"equals,omitempty"` // Variables which must equal the values.
+}
+
+func (c TaskEnvCondition) Clone() TaskEnvCondition {
+	c.Set = append([]string(nil), c.Set...)
+	c.Equals = maps.Clone(c.Equals)
+	return c
 }
 
 type setString map[string]struct{}
 
 type TaskFileNotChangedCondition struct {
-	Paths   setString `json:"paths,omitempty"`
-	Ignores setString `json:"ignores,omitempty"`
-	MD5     string    `json:"md5,omitempty"`
+	Paths    setString    `json:"paths,omitempty"`
+	Ignores  setString    `json:"ignores,omitempty"`
+	MD5      string       `json:"md5,omitempty"` // Digest of the whole files in the previous state format.
+	Manifest FileManifest `json:"manifest,omitempty"`
+	// Algorithm of the hashes in the manifest.
+	Algorithm HashAlgorithm `json:"algorithm,omitempty"`
 }
 
 func (t setString) MarshalJSON() ([]byte, error) {
@@ -108,8 +292,9 @@ func (cond *TaskFileNotChangedCondition) IsEqualRule(paths []string, ignores []s
 }
 
 type DocumentTaskScript struct {
-	Config ExecConfig
-	Script string
+	Config  ExecConfig
+	Script  string
+	Timeout time.Duration // Overrides the timeout of the task.
 }
 
 type NewDocumentOption func(ctx context.Context, d *DocumentConfig) error
@@ -129,7 +314,8 @@ func NewDocument(ctx context.Context, options ...NewDocumentOption) (Document, e
 	document := DocumentConfig{
 		ExecPathResolver: map[string]ExecConfig{},
 		Document: Document{
-			Tasks: map[string]DocumentTask{},
+			Tasks:      map[string]DocumentTask{},
+			GlobalEnvs: map[string]string{},
 		},
 	}
 
@@ -139,6 +325,10 @@ func NewDocument(ctx context.Context, options ...NewDocumentOption) (Document, e
 		}
 	}
 
+	if err := validateDependTasksExist(document.Document); err != nil {
+		return document.Document, err
+	}
+
 	if err := validateIsTaskDependencyCirculated(document.Document); err != nil {
 		return document.Document, err
 	}
@@ -146,6 +336,30 @@ func NewDocument(ctx context.Context, options ...NewDocumentOption) (Document, e
 	return document.Document, nil
 }
 
+var ErrUndefinedDependency = errors.New("task depends on undefined task")
+
+// Returns error with the closest task name when the task depends on undefined task.
+func validateDependTasksExist(document Document) error {
+	calls := document.SortedCalls()
+
+	for _, call := range calls {
+		task := document.Tasks[call]
+		for _, depend := range task.DependTasks {
+			if _, exist := document.Tasks[depend]; exist {
+				continue
+			}
+
+			msg := fmt.Sprintf("task '%s' depends on undefined task '%s'", call, depend)
+			if suggestion := SuggestClosest(depend, calls); suggestion != "" {
+				msg += fmt.Sprintf(" (did you mean '%s'?)", suggestion)
+			}
+			return task.Source.WrapError(errors.Mark(errors.New(msg), ErrUndefinedDependency))
+		}
+	}
+
+	return nil
+}
+
 func validateIsTaskDependencyCirculated(document Document) error {
 
 	mpRead := map[string]map[string]struct{}{}
@@ -167,14 +381,14 @@ func validateIsTaskDependencyCirculatedInternal(task string, mpHistory []string,
 		return nil
 	}
 
+	t := document.Tasks[task]
 	errCirculated := func(task string) error {
-		return errors.WithDetail(ErrCirculatedDependency, strings.Join(append(mpHistory, task+" (circulated)"), " -> "))
+		return t.Source.WrapError(errors.WithDetail(ErrCirculatedDependency, strings.Join(append(mpHistory, task+" (circulated)"), " -> ")))
 	}
 
 	depends := map[string]struct{}{}
 
 	mpHistory = append(mpHistory, task)
-	t := document.Tasks[task]
 	for i := range t.DependTasks {
 		depends[t.DependTasks[i]] = struct{}{}
 
@@ -0,0 +1,109 @@
+/*
+Copyright 2024 Kasai Kou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package model
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+// Algorithm of the content hash of the watched files, chosen per document.
+type HashAlgorithm string
+
+const (
+	HashMD5    HashAlgorithm = "md5" // Default.
+	HashSHA256 HashAlgorithm = "sha256"
+)
+
+func (algorithm HashAlgorithm) Validate() error {
+	switch algorithm {
+	case "", HashMD5, HashSHA256:
+		return nil
+	default:
+		return errors.Errorf("unknown hash algorithm '%s' (must be one of '%s' or '%s')", algorithm, HashMD5, HashSHA256)
+	}
+}
+
+// Returns the algorithm with the default applied.
+func (algorithm HashAlgorithm) OrDefault() HashAlgorithm {
+	if algorithm == "" {
+		return HashMD5
+	}
+
+	return algorithm
+}
+
+func (algorithm HashAlgorithm) New() hash.Hash {
+	if algorithm == HashSHA256 {
+		return sha256.New()
+	}
+
+	return md5.New()
+}
+
+// Size, modification time and content hash of a file watched by the conditions.
+type FileDigest struct {
+	Path    string    `json:"path"` // Relative to the working directory with slashes.
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+	Inode   uint64    `json:"inode,omitempty"` // Zero on the platforms without inodes.
+	Hash    string    `json:"hash"`
+}
+
+// Digests of the files sorted by the path.
+// Nil means the files are not recorded yet, unlike the empty manifest of no files.
+type FileManifest []FileDigest
+
+// Returns the hash of the paths and the contents, which identifies the whole files.
+func (m FileManifest) Digest() string {
+	hash := md5.New()
+	for i := range m {
+		fmt.Fprintf(hash, "%s\x00%s\n", m[i].Path, m[i].Hash)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// Returns the paths of the files added, removed and modified in current compared with m.
+// Both manifests must be sorted by the path.
+func (m FileManifest) Diff(current FileManifest) (added, removed, modified []string) {
+	i, j := 0, 0
+	for i < len(m) || j < len(current) {
+		switch {
+		case j >= len(current) || (i < len(m) && m[i].Path < current[j].Path):
+			removed = append(removed, m[i].Path)
+			i++
+		case i >= len(m) || current[j].Path < m[i].Path:
+			added = append(added, current[j].Path)
+			j++
+		default:
+			if m[i].Hash != current[j].Hash {
+				modified = append(modified, m[i].Path)
+			}
+			i++
+			j++
+		}
+	}
+
+	return added, removed, modified
+}
@@ -0,0 +1,51 @@
+/*
+Copyright 2024 Kasai Kou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileManifestDiff(t *testing.T) {
+	previous := FileManifest{
+		{Path: "a.go", Hash: "1"},
+		{Path: "b.go", Hash: "2"},
+		{Path: "c.go", Hash: "3"},
+	}
+
+	added, removed, modified := previous.Diff(FileManifest{
+		{Path: "a.go", Hash: "1"},
+		{Path: "c.go", Hash: "4"},
+		{Path: "d.go", Hash: "2"}, // Renamed from b.go.
+	})
+	assert.Equal(t, []string{"d.go"}, added)
+	assert.Equal(t, []string{"b.go"}, removed)
+	assert.Equal(t, []string{"c.go"}, modified)
+
+	added, removed, modified = previous.Diff(previous)
+	assert.Empty(t, added)
+	assert.Empty(t, removed)
+	assert.Empty(t, modified)
+	assert.Equal(t, previous.Digest(), FileManifest{{Path: "a.go", Hash: "1"}, {Path: "b.go", Hash: "2"}, {Path: "c.go", Hash: "3"}}.Digest())
+	assert.NotEqual(t, previous.Digest(), FileManifest{{Path: "a.go", Hash: "1"}, {Path: "b.go", Hash: "3"}, {Path: "c.go", Hash: "2"}}.Digest())
+
+	added, removed, _ = FileManifest{}.Diff(previous)
+	assert.Equal(t, []string{"a.go", "b.go", "c.go"}, added)
+	assert.Empty(t, removed)
+}
@@ -0,0 +1,66 @@
+/*
+Copyright 2024 Kasai Kou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package model
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+)
+
+// Returns values of the task parameters with the given values and defaults.
+// Returns error when the given parameter is not declared, the value is not allowed or the required parameter is not given.
+func (dt *DocumentTask) ResolveParams(given map[string]string) (map[string]string, error) {
+	declared := make([]string, 0, len(dt.Params))
+	for i := range dt.Params {
+		declared = append(declared, dt.Params[i].Name)
+	}
+
+	for name := range given {
+		if slices.Contains(declared, name) {
+			continue
+		}
+
+		msg := fmt.Sprintf("task '%s' has no parameter '%s'", dt.Call, name)
+		if suggestion := SuggestClosest(name, declared); suggestion != "" {
+			msg += fmt.Sprintf(" (did you mean '%s'?)", suggestion)
+		}
+		return nil, errors.New(msg)
+	}
+
+	values := make(map[string]string, len(dt.Params))
+	for _, param := range dt.Params {
+		value, exist := given[param.Name]
+		if !exist {
+			if param.Required {
+				return nil, errors.Errorf("task '%s' requires parameter '%s'", dt.Call, param.Name)
+			}
+			value = param.Default
+		}
+
+		if exist && len(param.Allowed) > 0 && !slices.Contains(param.Allowed, value) {
+			return nil, errors.Errorf("parameter '%s' of task '%s' must be one of [%s] (have: '%s')",
+				param.Name, dt.Call, strings.Join(param.Allowed, ", "), value)
+		}
+
+		values[param.Name] = value
+	}
+
+	return values, nil
+}
@@ -0,0 +1,46 @@
+/*
+Copyright 2024 Kasai Kou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveParams(t *testing.T) {
+	task := DocumentTask{
+		Call: "deploy",
+		Params: []TaskParam{
+			{Name: "env", Required: true, Allowed: []string{"dev", "staging", "prod"}},
+			{Name: "region", Default: "ap-northeast-1"},
+		},
+	}
+
+	values, err := task.ResolveParams(map[string]string{"env": "staging"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"env": "staging", "region": "ap-northeast-1"}, values)
+
+	_, err = task.ResolveParams(map[string]string{})
+	assert.ErrorContains(t, err, "requires parameter 'env'")
+
+	_, err = task.ResolveParams(map[string]string{"env": "test"})
+	assert.ErrorContains(t, err, "must be one of [dev, staging, prod]")
+
+	_, err = task.ResolveParams(map[string]string{"env": "dev", "regoin": "us-east-1"})
+	assert.ErrorContains(t, err, "did you mean 'region'?")
+}
@@ -0,0 +1,62 @@
+/*
+Copyright 2024 Kasai Kou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package model
+
+import (
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateDependTasksExist(t *testing.T) {
+	document := Document{
+		Tasks: map[string]DocumentTask{
+			"download": {},
+			"test": {
+				DependTasks: []string{"download"},
+			},
+		},
+	}
+
+	assert.NoError(t, validateDependTasksExist(document))
+}
+
+func TestValidateDependTasksNotExist(t *testing.T) {
+	document := Document{
+		Tasks: map[string]DocumentTask{
+			"download": {},
+			"test": {
+				Source:      SourcePosition{Filename: "docstak.md", Line: 3, Column: 4},
+				DependTasks: []string{"downlaod"},
+			},
+		},
+	}
+
+	err := validateDependTasksExist(document)
+	if assert.Error(t, err) {
+		assert.True(t, errors.Is(err, ErrUndefinedDependency))
+		assert.Equal(t, "docstak.md:3:4: task 'test' depends on undefined task 'downlaod' (did you mean 'download'?)", err.Error())
+	}
+}
+
+func TestSuggestClosest(t *testing.T) {
+	candidates := []string{"download", "test", "ci/fmt", "fmt"}
+	assert.Equal(t, "download", SuggestClosest("downlod", candidates))
+	assert.Equal(t, "ci/fmt", SuggestClosest("ci/fnt", candidates))
+	assert.Equal(t, "", SuggestClosest("deploy", candidates))
+}
@@ -18,9 +18,12 @@ package resolver
 
 import (
 	"context"
+	"log/slog"
 	"os/exec"
+	"strings"
 
 	"github.com/cockroachdb/errors"
+	"github.com/kasaikou/markflow/docstak"
 	"github.com/kasaikou/markflow/docstak/model"
 )
 
@@ -29,25 +32,53 @@ type ResolveOption struct {
 	Command string
 	CmdOpt  string
 	Args    []string
+	Arg0    bool
+	Mode    model.ExecMode
+	Ext     string // Extension of the script file, which is '.' + language by default.
+	Shebang bool
 }
 
+// Later options override the former ones for the same language.
+// The language is not resolved when its command is not found, even if a former option has resolved it.
 func NewDocumentWithPathResolver(options ...ResolveOption) model.NewDocumentOption {
 	return func(ctx context.Context, d *model.DocumentConfig) error {
 		for i := range options {
+			if err := options[i].Mode.Validate(); err != nil {
+				return errors.WithMessagef(err, "invalid interpreter of '%s'", strings.Join(options[i].Lang, "', '"))
+			}
+
 			for j := range options[i].Lang {
 				execPath, err := exec.LookPath(options[i].Command)
 				if err != nil {
 					if errors.Is(err, exec.ErrNotFound) {
+						if _, exist := d.ExecPathResolver[options[i].Lang[j]]; exist {
+							logger, exist := docstak.LookupLogger(ctx)
+							if !exist {
+								logger = slog.Default()
+							}
+							logger.Warn("interpreter is overridden but its command is not found", slog.String("lang", options[i].Lang[j]), slog.String("command", options[i].Command))
+							delete(d.ExecPathResolver, options[i].Lang[j])
+						}
 						continue
 					}
 
 					return err
 				}
-				d.ExecPathResolver[options[i].Lang[j]] = model.ExecConfig{
+				config := model.ExecConfig{
 					ExecPath: execPath,
 					CmdOpt:   options[i].CmdOpt,
 					Args:     options[i].Args,
+					Arg0:     options[i].Arg0,
+					Mode:     options[i].Mode,
+				}
+				if config.Mode == model.ExecModeFile {
+					config.Ext = options[i].Ext
+					if config.Ext == "" {
+						config.Ext = "." + options[i].Lang[j]
+					}
+					config.Shebang = options[i].Shebang
 				}
+				d.ExecPathResolver[options[i].Lang[j]] = config
 			}
 		}
 
@@ -0,0 +1,23 @@
+package resolver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kasaikou/markflow/docstak/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPathResolverWithoutLogger(t *testing.T) {
+	config := model.DocumentConfig{ExecPathResolver: map[string]model.ExecConfig{}}
+	option := NewDocumentWithPathResolver(
+		ResolveOption{Lang: []string{"sh"}, Command: "sh", CmdOpt: "-c"},
+		ResolveOption{Lang: []string{"sh"}, Command: "docstak-command-not-found"},
+	)
+
+	// The context without the logger is allowed for the warning of the overridden interpreter.
+	assert.NotPanics(t, func() {
+		assert.NoError(t, option(context.Background(), &config))
+	})
+	assert.NotContains(t, config.ExecPathResolver, "sh")
+}
@@ -0,0 +1,126 @@
+/*
+Copyright 2024 Kasai Kou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package docstak
+
+import (
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+type TaskStatus string
+
+const (
+	TaskSucceeded TaskStatus = "succeeded"
+	TaskFailed    TaskStatus = "failed"
+	TaskSkipped   TaskStatus = "skipped"   // Skipped by the skip conditions of the task.
+	TaskCancelled TaskStatus = "cancelled" // Canceled while running.
+	TaskNotRun    TaskStatus = "not-run"   // Not started because the dependent task failed or the execution was canceled.
+)
+
+// Result of a task executed by ExecuteContext().
+type TaskResult struct {
+	Call     string        `json:"task"`
+	Status   TaskStatus    `json:"status"`
+	ExitCode int           `json:"exit_code"`
+	Duration time.Duration `json:"duration"`
+	// The largest number of attempts among the code blocks of the task.
+	Attempts int    `json:"attempts"`
+	Reason   string `json:"reason,omitempty"` // Why the task was skipped.
+}
+
+type ExecuteResult struct {
+	ExitCode int
+	Tasks    []TaskResult // In the order of the document.
+}
+
+// Returned by the process exec function (see ExecuteOptProcessExec) with SkipError()
+// when the script is not executed because of the skip conditions.
+var ErrSkipped = errors.New("skipped")
+
+// Returns the error telling the script is skipped because of the reason.
+func SkipError(reason string) error {
+	return errors.Mark(errors.New(reason), ErrSkipped)
+}
+
+// Result of a code block.
+type scriptResult struct {
+	Exit     int
+	Attempts int
+	Skipped  bool
+	Reason   string
+}
+
+// Accumulates the results of the code blocks in a task.
+type taskRecorder struct {
+	result  TaskResult
+	start   time.Time
+	ran     int
+	skipped int
+}
+
+func newTaskRecorder(call string) *taskRecorder {
+	return &taskRecorder{
+		result: TaskResult{Call: call},
+		start:  time.Now(),
+	}
+}
+
+func (tr *taskRecorder) add(res scriptResult) {
+	tr.ran++
+	if res.Skipped {
+		tr.skipped++
+		tr.result.Reason = res.Reason
+	}
+	if res.Attempts > tr.result.Attempts {
+		tr.result.Attempts = res.Attempts
+	}
+	if res.Exit != 0 && tr.result.ExitCode == 0 {
+		tr.result.ExitCode = res.Exit
+	}
+}
+
+// Returns the result of the task skipped without running any code block.
+func (tr *taskRecorder) skip(reason string) TaskResult {
+	result := tr.result
+	result.Duration = time.Since(tr.start)
+	result.Status = TaskSkipped
+	result.Reason = reason
+	return result
+}
+
+func (tr *taskRecorder) finish(cancelled bool) TaskResult {
+	result := tr.result
+	result.Duration = time.Since(tr.start)
+
+	switch {
+	case cancelled:
+		result.Status = TaskCancelled
+	case result.ExitCode != 0:
+		result.Status = TaskFailed
+	case tr.ran > 0 && tr.ran == tr.skipped:
+		result.Status = TaskSkipped
+	default:
+		result.Status = TaskSucceeded
+	}
+
+	if result.Status != TaskSkipped {
+		result.Reason = ""
+	}
+
+	return result
+}
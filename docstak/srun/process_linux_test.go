@@ -0,0 +1,112 @@
+//go:build linux
+
+/*
+Copyright 2024 Kasai Kou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package srun
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Reports whether the process is running, regarding zombies as exited.
+func isProcessAlive(pid int) bool {
+	b, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return false
+	}
+
+	stat := string(b)
+	if i := strings.LastIndex(stat, ") "); i >= 0 && i+2 < len(stat) {
+		return stat[i+2] != 'Z'
+	}
+
+	return true
+}
+
+// Runs the script and cancels it after the script writes the process IDs of its descendants.
+func runAndCancel(t *testing.T, script string, gracePeriod time.Duration) (pids []int, elapsed time.Duration) {
+	t.Helper()
+	pidFile := filepath.Join(t.TempDir(), "pids")
+	runner := NewScriptRunner("/bin/sh", "-c", script)
+	runner.SetEnv("PIDFILE", pidFile)
+	runner.SetGracePeriod(gracePeriod)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	chExited := make(chan time.Time, 1)
+	go func() {
+		runner.RunContext(ctx)
+		chExited <- time.Now()
+	}()
+
+	// Wait for the descendants started.
+	for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+		b, _ := os.ReadFile(pidFile)
+		if fields := strings.Fields(string(b)); len(fields) >= 2 {
+			for i := range fields {
+				pid, err := strconv.Atoi(fields[i])
+				if err != nil {
+					t.Fatal(err)
+				}
+				pids = append(pids, pid)
+			}
+			break
+		}
+		if time.Since(start) > 5*time.Second {
+			t.Fatal("script does not start")
+		}
+	}
+
+	canceled := time.Now()
+	cancel()
+	select {
+	case exited := <-chExited:
+		return pids, exited.Sub(canceled)
+	case <-time.After(10 * time.Second):
+		t.Fatal("script is not stopped")
+		return nil, 0
+	}
+}
+
+func TestCancelKillsDescendants(t *testing.T) {
+	// Background jobs of non-interactive shells ignore SIGINT.
+	pids, _ := runAndCancel(t, "sleep 100 &\necho $! > \"$PIDFILE\"\nsh -c 'sleep 100' &\necho $! >> \"$PIDFILE\"\nwait\n", time.Second)
+
+	for _, pid := range pids {
+		assert.Eventually(t, func() bool { return !isProcessAlive(pid) }, time.Second, 10*time.Millisecond, "process %d survives", pid)
+	}
+}
+
+func TestCancelKillsDescendantsIgnoringSignals(t *testing.T) {
+	pids, elapsed := runAndCancel(t, "trap '' INT TERM\nsleep 100 &\necho $! > \"$PIDFILE\"\necho $$ >> \"$PIDFILE\"\nsleep 100\n", 100*time.Millisecond)
+
+	// Killed after the grace period of both SIGINT and SIGTERM.
+	assert.GreaterOrEqual(t, elapsed, 200*time.Millisecond)
+	assert.Less(t, elapsed, 5*time.Second)
+	for _, pid := range pids {
+		assert.Eventually(t, func() bool { return !isProcessAlive(pid) }, time.Second, 10*time.Millisecond, "process %d survives", pid)
+	}
+}
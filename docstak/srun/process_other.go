@@ -0,0 +1,35 @@
+//go:build !unix
+
+/*
+Copyright 2024 Kasai Kou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package srun
+
+import (
+	"os"
+	"os/exec"
+)
+
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// Sends the signal only to the script process, since process groups are not supported.
+func (sr *ScriptRunner) signal(stop stopSignal) error {
+	if stop == stopKill {
+		return sr.cmd.Process.Kill()
+	}
+
+	return sr.cmd.Process.Signal(os.Interrupt)
+}
@@ -0,0 +1,45 @@
+//go:build unix
+
+/*
+Copyright 2024 Kasai Kou
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package srun
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// Start the script in its own process group, so that its descendants can be signaled together.
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+// Sends the signal to the whole process group of the script.
+func (sr *ScriptRunner) signal(stop stopSignal) error {
+	sig := syscall.SIGKILL
+	switch stop {
+	case stopInterrupt:
+		sig = syscall.SIGINT
+	case stopTerminate:
+		sig = syscall.SIGTERM
+	}
+
+	return syscall.Kill(-sr.cmd.Process.Pid, sig)
+}
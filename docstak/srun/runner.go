@@ -22,55 +22,198 @@ import (
 	"io"
 	"os"
 	"os/exec"
+	"strings"
 	"time"
+
+	"github.com/cockroachdb/errors"
 )
 
 type ScriptRunner struct {
-	cmd *exec.Cmd
+	cmd    *exec.Cmd
+	script string
+	file   *ScriptFile
+	// Index of the argument replaced with the script filename.
+	fileArgIdx int
+	// Time to wait for the script to exit after each signal on cancellation.
+	gracePeriod time.Duration
+	// Writers of the readers returned by Stdout() and Stderr(), closed after the script exits.
+	pipes []*io.PipeWriter
 }
 
+const DefaultGracePeriod = 10 * time.Second
+
+// Time to wait for the output after the script exits, which its background processes may keep open.
+const OutputWaitDelay = time.Second
+
+type stopSignal int
+
+const (
+	stopInterrupt stopSignal = iota
+	stopTerminate
+	stopKill
+)
+
+// Configuration of the temporary file the script is written to.
+type ScriptFile struct {
+	Ext     string // Extension of the file like '.go'.
+	Shebang string // Interpreter line written at the head of the file unless the script has one.
+}
+
+// Returns the runner passing the script as an argument following cmdOpt, and also as stdin.
 func NewScriptRunner(execPath string, cmdOpt string, script string, args ...string) *ScriptRunner {
 
 	args = append(args, cmdOpt, script)
 	runner := &ScriptRunner{
-		cmd: exec.Command(execPath, args...),
+		cmd:         exec.Command(execPath, args...),
+		gracePeriod: DefaultGracePeriod,
 	}
 
 	runner.cmd.Stdin = bytes.NewBufferString(script)
 	return runner
 }
 
-func (sr *ScriptRunner) SetWorkingDir(dir string)   { sr.cmd.Dir = dir }
-func (sr *ScriptRunner) SetEnviron(environ string)  { sr.cmd.Env = append(sr.cmd.Env, environ) }
-func (sr *ScriptRunner) SetEnv(key, value string)   { sr.cmd.Env = append(sr.cmd.Env, key+"="+value) }
-func (sr *ScriptRunner) Stdout() (io.Reader, error) { return sr.cmd.StdoutPipe() }
-func (sr *ScriptRunner) Stderr() (io.Reader, error) { return sr.cmd.StderrPipe() }
+// Returns the runner passing the script only as stdin.
+func NewStdinScriptRunner(execPath string, cmdOpt string, script string, args ...string) *ScriptRunner {
+
+	if cmdOpt != "" {
+		args = append(args, cmdOpt)
+	}
+	runner := &ScriptRunner{
+		cmd:         exec.Command(execPath, args...),
+		gracePeriod: DefaultGracePeriod,
+	}
+
+	runner.cmd.Stdin = bytes.NewBufferString(script)
+	return runner
+}
+
+// Returns the runner passing the filename of the script following cmdOpt.
+// The script is written to a temporary file when it runs, and the file is removed after the process exits.
+func NewFileScriptRunner(execPath string, cmdOpt string, script string, file ScriptFile, args ...string) *ScriptRunner {
+
+	if cmdOpt != "" {
+		args = append(args, cmdOpt)
+	}
+	args = append(args, "")
+	runner := &ScriptRunner{
+		cmd:         exec.Command(execPath, args...),
+		script:      script,
+		file:        &file,
+		gracePeriod: DefaultGracePeriod,
+	}
+	runner.fileArgIdx = len(runner.cmd.Args) - 1
+
+	return runner
+}
+
+func (sr *ScriptRunner) AppendArgs(args ...string)      { sr.cmd.Args = append(sr.cmd.Args, args...) }
+func (sr *ScriptRunner) SetWorkingDir(dir string)       { sr.cmd.Dir = dir }
+func (sr *ScriptRunner) SetGracePeriod(d time.Duration) { sr.gracePeriod = d }
+func (sr *ScriptRunner) SetEnviron(environ string)      { sr.cmd.Env = append(sr.cmd.Env, environ) }
+func (sr *ScriptRunner) SetEnv(key, value string)       { sr.cmd.Env = append(sr.cmd.Env, key+"="+value) }
+func (sr *ScriptRunner) Stdout() (io.Reader, error)     { return sr.pipe(&sr.cmd.Stdout) }
+func (sr *ScriptRunner) Stderr() (io.Reader, error)     { return sr.pipe(&sr.cmd.Stderr) }
+
+// Returns the reader of the output, which must be read until EOF.
+// Unlike exec.Cmd.StdoutPipe(), the output is not lost when the script exits before it is read.
+func (sr *ScriptRunner) pipe(dest *io.Writer) (io.Reader, error) {
+	if *dest != nil {
+		return nil, errors.New("output of the script is already set")
+	}
+
+	reader, writer := io.Pipe()
+	*dest = writer
+	sr.pipes = append(sr.pipes, writer)
+	return reader, nil
+}
+
+// Writes the script to a temporary file and returns the function to remove it.
+func (sr *ScriptRunner) writeScriptFile() (remove func(), err error) {
+	f, err := os.CreateTemp("", "docstak-*"+sr.file.Ext)
+	if err != nil {
+		return nil, err
+	}
+	remove = func() { os.Remove(f.Name()) }
+
+	content := sr.script
+	if sr.file.Shebang != "" && !strings.HasPrefix(content, "#!") {
+		content = "#!" + sr.file.Shebang + "\n" + content
+	}
+
+	if _, err := f.WriteString(content); err != nil {
+		f.Close()
+		remove()
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		remove()
+		return nil, err
+	}
+
+	// Allow the interpreter to execute the file by itself.
+	if err := os.Chmod(f.Name(), 0700); err != nil {
+		remove()
+		return nil, err
+	}
+
+	sr.cmd.Args[sr.fileArgIdx] = f.Name()
+	return remove, nil
+}
 
 func (sr *ScriptRunner) RunContext(ctx context.Context) (int, error) {
 
+	// Wait returns after all output is written to the pipes.
+	defer func() {
+		for i := range sr.pipes {
+			sr.pipes[i].Close()
+		}
+	}()
+	sr.cmd.WaitDelay = OutputWaitDelay
+
+	if sr.file != nil {
+		remove, err := sr.writeScriptFile()
+		if err != nil {
+			return -1, err
+		}
+		defer remove()
+	}
+
+	setProcessGroup(sr.cmd)
+	if err := sr.cmd.Start(); err != nil {
+		return -1, err
+	}
+
 	var cmdErr error
 	onFin := make(chan struct{}, 1)
 	go func() {
 		defer close(onFin)
-		if cmdErr = sr.cmd.Start(); cmdErr != nil {
-			return
-		} else if cmdErr = sr.cmd.Wait(); cmdErr != nil {
-			return
+		cmdErr = sr.cmd.Wait()
+		// The output left open by the background processes is not an error of the script.
+		if errors.Is(cmdErr, exec.ErrWaitDelay) {
+			cmdErr = nil
 		}
 	}()
 
 	select {
 	case <-ctx.Done():
-		if err := sr.cmd.Process.Signal(os.Interrupt); err == nil {
-			timer := time.NewTimer(10 * time.Second)
+		// Stop the script and its descendants gradually, interrupt, terminate, and then kill.
+		for _, stop := range []stopSignal{stopInterrupt, stopTerminate} {
+			if err := sr.signal(stop); err != nil {
+				break
+			}
+
+			timer := time.NewTimer(sr.gracePeriod)
 			select {
 			case <-onFin:
+				timer.Stop()
+				// Descendants remaining after the script exits are killed.
+				sr.signal(stopKill)
 				return sr.cmd.ProcessState.ExitCode(), cmdErr
 			case <-timer.C:
 			}
 		}
 
-		if err := sr.cmd.Process.Kill(); err != nil {
+		if err := sr.signal(stopKill); err != nil {
 			return sr.cmd.ProcessState.ExitCode(), err
 		}
 
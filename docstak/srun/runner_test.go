@@ -17,11 +17,16 @@ limitations under the License.
 package srun
 
 import (
+	"bufio"
 	"context"
 	"io"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -47,3 +52,110 @@ func TestRunnerSample(t *testing.T) {
 	assert.NoError(t, err)
 	wg.Wait()
 }
+
+func runForOutput(t *testing.T, ctx context.Context, runner *ScriptRunner) (string, int, error) {
+	t.Helper()
+	stdout, err := runner.Stdout()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output := make(chan string, 1)
+	go func() {
+		b, _ := io.ReadAll(stdout)
+		output <- string(b)
+	}()
+
+	exit, err := runner.RunContext(ctx)
+	return <-output, exit, err
+}
+
+func TestRunnerOutputReadLate(t *testing.T) {
+	runner := NewScriptRunner("/bin/sh", "-c", "seq 1 10000\necho error >&2\n")
+	stdout, _ := runner.Stdout()
+	stderr, _ := runner.Stderr()
+
+	// The script exits before the output is read.
+	outputs := make(chan string, 2)
+	for _, reader := range []io.Reader{stdout, stderr} {
+		go func(reader io.Reader) {
+			time.Sleep(100 * time.Millisecond)
+			b, _ := io.ReadAll(reader)
+			outputs <- string(b)
+		}(reader)
+	}
+
+	exit, err := runner.RunContext(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, exit)
+
+	received := []string{<-outputs, <-outputs}
+	assert.Contains(t, received, "error\n")
+	assert.Contains(t, received, func() string {
+		b := strings.Builder{}
+		for i := 1; i <= 10000; i++ {
+			b.WriteString(strconv.Itoa(i) + "\n")
+		}
+		return b.String()
+	}())
+}
+
+func TestRunnerOutputBackground(t *testing.T) {
+	runner := NewScriptRunner("/bin/sh", "-c", "sleep 5 &\necho done\n")
+
+	// The background process keeping the output open does not block the runner.
+	start := time.Now()
+	output, exit, err := runForOutput(t, context.Background(), runner)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, exit)
+	assert.Equal(t, "done\n", output)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestStdinRunner(t *testing.T) {
+	runner := NewStdinScriptRunner("/bin/sh", "-s", "echo \"$1\"\n")
+	runner.AppendArgs("hello")
+
+	output, _, err := runForOutput(t, context.Background(), runner)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello\n", output)
+}
+
+func TestFileRunner(t *testing.T) {
+	runner := NewFileScriptRunner("/bin/sh", "", "head -n 1 \"$0\"\necho \"$0\" \"$1\"\n", ScriptFile{Ext: ".sh", Shebang: "/bin/sh"})
+	runner.AppendArgs("hello")
+
+	output, _, err := runForOutput(t, context.Background(), runner)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if !assert.Len(t, lines, 2) {
+		return
+	}
+	assert.Equal(t, "#!/bin/sh", lines[0])
+
+	filename, arg, _ := strings.Cut(lines[1], " ")
+	assert.Equal(t, ".sh", filepath.Ext(filename))
+	assert.Equal(t, "hello", arg)
+	assert.NoFileExists(t, filename)
+}
+
+func TestFileRunnerCanceled(t *testing.T) {
+	runner := NewFileScriptRunner("/bin/sh", "", "echo \"$0\"\nexec sleep 10\n", ScriptFile{})
+	stdout, _ := runner.Stdout()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	filename := make(chan string, 1)
+	go func() {
+		line, _ := bufio.NewReader(stdout).ReadString('\n')
+		filename <- strings.TrimSpace(line)
+		cancel()
+		io.Copy(io.Discard, stdout)
+	}()
+
+	_, err := runner.RunContext(ctx)
+	assert.Error(t, err)
+	assert.NoFileExists(t, <-filename)
+}